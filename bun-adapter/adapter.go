@@ -0,0 +1,248 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/iancoleman/strcase"
+	"github.com/uptrace/bun"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var toSQLOp = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"lt":   "<",
+	"lte":  "<=",
+	"gt":   ">",
+	"gte":  ">=",
+	"add":  "+",
+	"sub":  "-",
+	"mult": "*",
+	"div":  "/",
+	"mod":  "%",
+	"in":   "IN",
+}
+
+// negateOp is used to push "not" down to the comparisons, because bun has no way of negating a where group.
+// Each pair is equivalent under SQL three-valued logic, e.g. NOT (a < b) and a >= b are both NULL when a is NULL.
+var negateOp = map[string]string{
+	"eq":  "ne",
+	"ne":  "eq",
+	"lt":  "gte",
+	"lte": "gt",
+	"gt":  "lte",
+	"gte": "lt",
+	"in":  "notIn",
+}
+
+var toSQLField = map[string]string{}
+
+var ErrExpressionExpected = errors.New("expected expression")
+
+type filterOpExpression = enginev1.PlanResourcesFilter_Expression_Operand_Expression
+type filterOpValue = enginev1.PlanResourcesFilter_Expression_Operand_Value
+type filterOpVariable = enginev1.PlanResourcesFilter_Expression_Operand_Variable
+type filterOp = enginev1.PlanResourcesFilter_Expression_Operand
+
+// QueryBuilderFunc applies a query plan to a query, e.g. db.NewSelect().Model(&contacts).ApplyQueryBuilder(f).
+type QueryBuilderFunc = func(q bun.QueryBuilder) bun.QueryBuilder
+
+// whereFunc adds a condition to q, joined to the preceding conditions with OR if or is true and AND otherwise.
+type whereFunc func(q bun.QueryBuilder, or bool) bun.QueryBuilder
+
+type BuildQueryBuilderType func(e *filterOpExpression) (QueryBuilderFunc, error)
+
+func (t BuildQueryBuilderType) BuildQueryBuilder(e *filterOpExpression) (QueryBuilderFunc, error) {
+	return t(e)
+}
+
+func BuildQueryBuilder(e *filterOpExpression) (QueryBuilderFunc, error) {
+	return buildQueryBuilder(e, nil)
+}
+
+// buildQueryBuilder builds the query builder with the columns named by names, see fieldNames.
+func buildQueryBuilder(e *filterOpExpression, names fieldNames) (QueryBuilderFunc, error) {
+	if e == nil {
+		return func(q bun.QueryBuilder) bun.QueryBuilder { return q }, nil
+	}
+	w, err := buildWhere(e, false, names)
+	if err != nil {
+		return nil, err
+	}
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		return w(q, false)
+	}, nil
+}
+
+func buildWhere(e *filterOpExpression, negate bool, names fieldNames) (whereFunc, error) {
+	switch e.Expression.Operator {
+	case "or", "and":
+		if len(e.Expression.Operands) == 0 {
+			return nil, fmt.Errorf("expected at least one operand: op = %q", e.Expression.Operator)
+		}
+		ws := make([]whereFunc, len(e.Expression.Operands))
+		for i, o := range e.Expression.Operands {
+			oe, ok := o.GetNode().(*filterOpExpression)
+			if !ok {
+				return nil, ErrExpressionExpected
+			}
+			w, err := buildWhere(oe, negate, names)
+			if err != nil {
+				return nil, err
+			}
+			ws[i] = w
+		}
+		// De Morgan: a negated OR is an AND of the negated operands and vice versa.
+		joinWithOr := (e.Expression.Operator == "or") != negate
+		return func(q bun.QueryBuilder, or bool) bun.QueryBuilder {
+			return q.WhereGroup(sep(or), func(q bun.QueryBuilder) bun.QueryBuilder {
+				for _, w := range ws {
+					q = w(q, joinWithOr)
+				}
+				return q
+			})
+		}, nil
+	case "not":
+		if len(e.Expression.Operands) != 1 {
+			return nil, fmt.Errorf("expected a unary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+		}
+		oe, ok := e.Expression.Operands[0].GetNode().(*filterOpExpression)
+		if !ok {
+			return nil, ErrExpressionExpected
+		}
+		return buildWhere(oe, !negate, names)
+	default:
+		query, args, err := buildComparison(e, negate, names)
+		if err != nil {
+			return nil, err
+		}
+		return func(q bun.QueryBuilder, or bool) bun.QueryBuilder {
+			if or {
+				return q.WhereOr(query, args...)
+			}
+			return q.Where(query, args...)
+		}, nil
+	}
+}
+
+// buildComparison returns the query and the arguments of a comparison in the bun "?" placeholder syntax.
+func buildComparison(e *filterOpExpression, negate bool, names fieldNames) (query string, args []interface{}, err error) {
+	const numOperands = 2
+	if len(e.Expression.Operands) != numOperands {
+		return "", nil, fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+	}
+	operator := e.Expression.Operator
+	if negate {
+		var ok bool
+		if operator, ok = negateOp[operator]; !ok {
+			return "", nil, fmt.Errorf("cannot negate operation %q", e.Expression.Operator)
+		}
+	}
+	if operator == "eq" || operator == "ne" {
+		if query, args, ok := buildNullCheck(operator, e.Expression.Operands, names); ok {
+			return query, args, nil
+		}
+	}
+	op, ok := toSQLOp[operator]
+	switch {
+	case operator == "notIn":
+		op = "NOT IN"
+	case !ok:
+		return "", nil, fmt.Errorf("unsupported operation %q", operator)
+	}
+	b := new(strings.Builder)
+	for i, operand := range e.Expression.Operands {
+		switch eo := operand.Node.(type) {
+		case *filterOpExpression:
+			q, a, err := buildComparison(eo, false, names)
+			if err != nil {
+				return "", nil, err
+			}
+			b.WriteString("(?)")
+			args = append(args, bun.SafeQuery(q, a...))
+		case *filterOpVariable:
+			b.WriteRune('?')
+			args = append(args, bun.Ident(names.column(eo.Variable)))
+		case *filterOpValue:
+			v := eo.Value.AsInterface()
+			if l, ok := v.([]interface{}); ok && op != "IN" && op != "NOT IN" {
+				return "", nil, fmt.Errorf("operation %q: unexpected list value", operator)
+			} else if ok {
+				b.WriteString("(?)")
+				args = append(args, bun.In(l))
+			} else {
+				b.WriteRune('?')
+				args = append(args, v)
+			}
+		default:
+			return "", nil, errors.New("unknown Node type")
+		}
+		if i == 0 {
+			b.WriteRune(' ')
+			b.WriteString(op)
+			b.WriteRune(' ')
+		}
+	}
+	return b.String(), args, nil
+}
+
+// buildNullCheck turns a comparison between a column and null into IS [NOT] NULL.
+func buildNullCheck(operator string, operands []*filterOp, names fieldNames) (string, []interface{}, bool) {
+	var column string
+	for i, operand := range operands {
+		if v, ok := operand.GetNode().(*filterOpVariable); ok {
+			if r, ok := operands[1-i].GetNode().(*filterOpValue); ok {
+				if _, ok := r.Value.GetKind().(*structpb.Value_NullValue); ok {
+					column = names.column(v.Variable)
+				}
+			}
+		}
+	}
+	if column == "" {
+		return "", nil, false
+	}
+	if operator == "eq" {
+		return "? IS NULL", []interface{}{bun.Ident(column)}, true
+	}
+	return "? IS NOT NULL", []interface{}{bun.Ident(column)}, true
+}
+
+func sep(or bool) string {
+	if or {
+		return " OR "
+	}
+	return " AND "
+}
+
+// fieldNames maps attribute names to column names, e.g. "ownerId": "owner_id", taking precedence over toSQLField.
+type fieldNames map[string]string
+
+// column returns the column name of a plan variable.
+func (m fieldNames) column(variable string) string {
+	if s, ok := m[attrName(variable)]; ok {
+		return s
+	}
+	return getFieldName(variable)
+}
+
+func getFieldName(name string) string {
+	name = attrName(name)
+
+	if s, ok := toSQLField[name]; ok {
+		return s
+	}
+
+	return strcase.ToSnake(name)
+}
+
+// attrName returns the name of the resource attribute a plan variable refers to, e.g. ownerId for R.attr.ownerId.
+func attrName(variable string) string {
+	variable = strings.TrimPrefix(variable, "request.resource.attr.")
+	return strings.TrimPrefix(variable, "R.attr.")
+}
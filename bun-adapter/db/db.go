@@ -0,0 +1,143 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+//go:embed seed.json
+var seed []byte
+
+type queryBuilderBuilder interface {
+	BuildQueryBuilder(e *enginev1.PlanResourcesFilter_Expression_Operand_Expression) (func(bun.QueryBuilder) bun.QueryBuilder, error)
+}
+
+type Client struct {
+	client              *bun.DB
+	queryBuilderBuilder queryBuilderBuilder
+}
+
+func (cli *Client) GetAllContacts(ctx context.Context) (res []*Contact, err error) {
+	err = cli.client.NewSelect().Model(&res).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (cli *Client) GetContacts(ctx context.Context, filter *enginev1.PlanResourcesFilter) (res []*Contact, err error) {
+	if filter == nil {
+		return nil, errors.New("\"filter\" is nil")
+	}
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		return nil, nil
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		return cli.GetAllContacts(ctx)
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		f, err := cli.queryBuilderBuilder.BuildQueryBuilder(filter.Condition.GetNode().(*enginev1.PlanResourcesFilter_Expression_Operand_Expression))
+		if err != nil {
+			return nil, err
+		}
+		err = cli.client.NewSelect().Model(&res).ApplyQueryBuilder(f).Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+}
+
+func New(b queryBuilderBuilder, url string) *Client {
+	sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(url)))
+	return &Client{client: bun.NewDB(sqldb, pgdialect.New()), queryBuilderBuilder: b}
+}
+
+func (cli *Client) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	user := new(User)
+	err := cli.client.NewSelect().Model(user).Where("? = ?", bun.Ident("username"), username).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+//go:embed schema.sql
+var schemaSQL string
+
+// SetupDatabase creates the schema and loads the seed data. The schema script sets the search path,
+// so everything runs in a single transaction to keep to the same connection.
+func SetupDatabase(ctx context.Context, db *bun.DB) error {
+	var data []Seed
+	err := json.Unmarshal(seed, &data)
+	if err != nil {
+		return fmt.Errorf("failed to read from seed file: %w", err)
+	}
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, schemaSQL); err != nil {
+			return fmt.Errorf("failed creating schema: %w", err)
+		}
+		for _, u := range data {
+			user := &User{Name: u.Name, Username: u.Username, Email: u.Email, Role: u.Role, Department: u.Department}
+			if _, err := tx.NewInsert().Model(user).Exec(ctx); err != nil {
+				return fmt.Errorf("failed creating a user: %w", err)
+			}
+			for _, c := range u.Contacts {
+				var cid *int
+				if c.Company != nil {
+					company := &Company{Name: c.Company.Name}
+					if _, err := tx.NewInsert().Model(company).Exec(ctx); err != nil {
+						return fmt.Errorf("failed creating a company: %w", err)
+					}
+					cid = &company.ID
+				}
+				contact := &Contact{
+					FirstName:      c.FirstName,
+					LastName:       c.LastName,
+					OwnerID:        user.ID,
+					CompanyID:      cid,
+					Active:         c.Active,
+					MarketingOptIn: c.MarketingOptIn,
+				}
+				if _, err := tx.NewInsert().Model(contact).Exec(ctx); err != nil {
+					return fmt.Errorf("failed creating a contact: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+type Seed struct {
+	Name       string `json:"name"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	Department string `json:"department"`
+	Contacts   []struct {
+		Company *struct {
+			Name string `json:"name"`
+		} `json:"company"`
+		FirstName      string `json:"firstName"`
+		LastName       string `json:"lastName"`
+		MarketingOptIn bool   `json:"marketingOptIn"`
+		Active         bool   `json:"active"`
+	} `json:"contacts,omitempty"`
+}
@@ -0,0 +1,44 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type Contact struct {
+	bun.BaseModel `bun:"table:contacts"`
+
+	CreatedAt      time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt      time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	CompanyID      *int
+	FirstName      string
+	LastName       string
+	ID             int `bun:",pk,autoincrement"`
+	OwnerID        int
+	Active         bool
+	MarketingOptIn bool
+}
+
+type Company struct {
+	bun.BaseModel `bun:"table:companies"`
+
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	Name      string
+	ID        int `bun:",pk,autoincrement"`
+}
+
+type User struct {
+	bun.BaseModel `bun:"table:users"`
+
+	Username   string
+	Email      string
+	Name       string
+	Role       string
+	Department string
+	ID         int `bun:",pk,autoincrement"`
+}
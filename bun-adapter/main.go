@@ -0,0 +1,122 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+
+	"github.com/cerbos/cerbos-go-adapters/internal/planfile"
+)
+
+const usage = `Usage: bun-adapter <command> [flags]
+
+Commands:
+  sql      Print the SQL predicate of a query plan
+
+Run bun-adapter <command> -h for the flags of a command.
+`
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+	switch args[0] {
+	case "sql":
+		return runSQL(ctx, args[1:], out)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("unknown command %q, run bun-adapter -h for the list of commands", args[0])
+	}
+}
+
+func runSQL(_ context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sql", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bun-adapter sql [flags] [plan.json|plan.yaml|-]")
+		fmt.Fprintln(fs.Output(), "\nPrints the SQL predicate of a PlanResourcesResponse or a PlanResourcesFilter read from a file or stdin.")
+		fmt.Fprintln(fs.Output(), "bun formats the arguments into the query, so they are always inlined.")
+		fs.PrintDefaults()
+	}
+	sqlDialect := fs.String("dialect", "postgres", "SQL dialect: postgres")
+	mapping := fs.String("mapping", "", "YAML file mapping attribute names to column names, e.g. ownerId: owner_id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("expected at most one plan file")
+	}
+	if *sqlDialect != "postgres" {
+		return fmt.Errorf("unsupported dialect %q", *sqlDialect)
+	}
+	var names fieldNames
+	if *mapping != "" {
+		var err error
+		if names, err = planfile.ReadMapping(*mapping); err != nil {
+			return err
+		}
+	}
+	plan, err := planfile.ReadPlan(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	filter := plan.GetFilter()
+
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		_, err = fmt.Fprintln(out, "TRUE")
+		return err
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		_, err = fmt.Fprintln(out, "FALSE")
+		return err
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+	default:
+		return fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+	e, ok := filter.Condition.GetNode().(*filterOpExpression)
+	if !ok {
+		return ErrExpressionExpected
+	}
+	f, err := buildQueryBuilder(e, names)
+	if err != nil {
+		return err
+	}
+	const prefix = `SELECT * FROM "t" WHERE `
+	q := bunDB.NewSelect().ColumnExpr("*").Table("t").ApplyQueryBuilder(f).String()
+	where, ok := strings.CutPrefix(q, prefix)
+	if !ok {
+		return fmt.Errorf("unexpected query %q", q)
+	}
+	_, err = fmt.Fprintln(out, where)
+	return err
+}
+
+// bunDB formats queries with the Postgres dialect. Values are inlined by bun, so no connection is needed.
+var bunDB = bun.NewDB(sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN("postgres://localhost/postgres"))), pgdialect.New())
@@ -0,0 +1,44 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunSQL(t *testing.T) {
+	is := require.New(t)
+	plan := filepath.Join(t.TempDir(), "plan.yaml")
+	is.NoError(os.WriteFile(plan, []byte(`
+filter:
+  kind: KIND_CONDITIONAL
+  condition:
+    expression:
+      operator: eq
+      operands:
+        - variable: request.resource.attr.ownerId
+        - value: "2"
+`), 0o600))
+
+	mapping := filepath.Join(t.TempDir(), "mapping.yaml")
+	is.NoError(os.WriteFile(mapping, []byte("ownerId: owner\n"), 0o600))
+
+	out := new(bytes.Buffer)
+	is.NoError(run(context.Background(), []string{"sql", plan}, out))
+	is.Equal("(\"owner_id\" = '2')\n", out.String())
+
+	out.Reset()
+	is.NoError(run(context.Background(), []string{"sql", "-mapping", mapping, plan}, out))
+	is.Equal("(\"owner\" = '2')\n", out.String())
+
+	out.Reset()
+	is.NoError(run(context.Background(), []string{"sql", plan}, out))
+	is.Equal("(\"owner_id\" = '2')\n", out.String())
+}
@@ -0,0 +1,292 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/iancoleman/strcase"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// toRangeOp maps the comparison operators of a query plan to the bounds of a range query.
+var toRangeOp = map[string]string{
+	"lt":  "lt",
+	"lte": "lte",
+	"gt":  "gt",
+	"gte": "gte",
+}
+
+// flipRangeOp is used when the value is on the left-hand side of a comparison, e.g. 10 < R.attr.age.
+var flipRangeOp = map[string]string{
+	"lt":  "gt",
+	"lte": "gte",
+	"gt":  "lt",
+	"gte": "lte",
+}
+
+// negateOp maps the comparisons to their negation, which holds under SQL three-valued logic, e.g. NOT (x < 1) is x >= 1.
+var negateOp = map[string]string{
+	"eq":  "ne",
+	"ne":  "eq",
+	"lt":  "gte",
+	"lte": "gt",
+	"gt":  "lte",
+	"gte": "lt",
+}
+
+var toESField = map[string]string{}
+
+var (
+	ErrExpressionExpected = errors.New("expected expression")
+	ErrUnsupportedOperand = errors.New("expected a variable and a value")
+)
+
+type filterOpExpression = enginev1.PlanResourcesFilter_Expression_Operand_Expression
+type filterOpValue = enginev1.PlanResourcesFilter_Expression_Operand_Value
+type filterOpVariable = enginev1.PlanResourcesFilter_Expression_Operand_Variable
+type filterOp = enginev1.PlanResourcesFilter_Expression_Operand
+
+// Query is a node of the Elasticsearch/OpenSearch query DSL.
+type Query = map[string]any
+
+// BuildFilter converts a query plan filter into a query DSL document suitable for the "query" field of a search request.
+func BuildFilter(filter *enginev1.PlanResourcesFilter) (Query, error) {
+	return buildFilter(filter, nil)
+}
+
+// buildFilter converts the filter with the fields named by names, see fieldNames.
+func buildFilter(filter *enginev1.PlanResourcesFilter, names fieldNames) (Query, error) {
+	if filter == nil {
+		return nil, errors.New("\"filter\" is nil")
+	}
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		return Query{"match_none": Query{}}, nil
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		return Query{"match_all": Query{}}, nil
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		e, ok := filter.Condition.GetNode().(*filterOpExpression)
+		if !ok {
+			return nil, ErrExpressionExpected
+		}
+		return buildQuery(e, names)
+	default:
+		return nil, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+}
+
+// BuildFilterJSON is like BuildFilter but returns the query DSL encoded as JSON.
+func BuildFilterJSON(filter *enginev1.PlanResourcesFilter) ([]byte, error) {
+	q, err := BuildFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(q)
+}
+
+func BuildQuery(e *filterOpExpression) (q Query, err error) {
+	return buildQuery(e, nil)
+}
+
+func buildQuery(e *filterOpExpression, names fieldNames) (q Query, err error) {
+	if e == nil {
+		return nil, nil
+	}
+	return buildQueryImpl(e, names, false)
+}
+
+// buildQueryImpl builds the query of e, or of its negation if negate is true. The negation is pushed down to
+// the comparisons, so that a negated comparison does not match the documents missing the field, like ne.
+func buildQueryImpl(e *filterOpExpression, names fieldNames, negate bool) (q Query, err error) {
+	switch e.Expression.Operator {
+	case "or", "and":
+		qs := make([]any, len(e.Expression.Operands))
+		for i, o := range e.Expression.Operands {
+			if oe, ok := o.GetNode().(*filterOpExpression); ok {
+				qs[i], err = buildQueryImpl(oe, names, negate)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, ErrExpressionExpected
+			}
+		}
+		// De Morgan: a negated OR is an AND of the negated operands and vice versa.
+		if (e.Expression.Operator == "or") != negate {
+			return boolQuery("should", qs...), nil
+		}
+		return boolQuery("must", qs...), nil
+	case "not":
+		if len(e.Expression.Operands) != 1 {
+			return nil, fmt.Errorf("expected a unary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+		}
+		if oe, ok := e.Expression.Operands[0].GetNode().(*filterOpExpression); ok {
+			return buildQueryImpl(oe, names, !negate)
+		}
+		return nil, ErrExpressionExpected
+	default:
+		const numOperands = 2
+		if len(e.Expression.Operands) != numOperands {
+			return nil, fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+		}
+		op := e.Expression.Operator
+		if !negate {
+			return buildLeaf(op, e.Expression.Operands, names)
+		}
+		if neg, ok := negateOp[op]; ok {
+			return buildLeaf(neg, e.Expression.Operands, names)
+		}
+		q, err := buildLeaf(op, e.Expression.Operands, names)
+		if err != nil {
+			return nil, err
+		}
+		field, _, _, err := fieldAndValue(e.Expression.Operands, names)
+		if err != nil {
+			return nil, err
+		}
+		return notQuery(field, q), nil
+	}
+}
+
+func buildLeaf(op string, operands []*filterOp, names fieldNames) (Query, error) {
+	field, value, swapped, err := fieldAndValue(operands, names)
+	if err != nil {
+		return nil, fmt.Errorf("operation %q: %w", op, err)
+	}
+	switch op {
+	case "eq":
+		if isNull(value) {
+			return boolQuery("must_not", exists(field)), nil
+		}
+		return term(op, field, value)
+	case "ne":
+		if isNull(value) {
+			return exists(field), nil
+		}
+		q, err := term(op, field, value)
+		if err != nil {
+			return nil, err
+		}
+		return notQuery(field, q), nil
+	case "lt", "lte", "gt", "gte":
+		rangeOp := toRangeOp[op]
+		if swapped {
+			rangeOp = flipRangeOp[op]
+		}
+		return Query{"range": Query{field: Query{rangeOp: value.AsInterface()}}}, nil
+	case "in":
+		if swapped {
+			// "value in R.attr.list": a term query matches documents where any element of the array field equals the value.
+			return term(op, field, value)
+		}
+		if _, ok := value.GetKind().(*structpb.Value_ListValue); !ok {
+			return nil, fmt.Errorf("operation %q: expected a list value", op)
+		}
+		return Query{"terms": Query{field: value.AsInterface()}}, nil
+	case "startsWith", "endsWith", "contains":
+		s, ok := value.GetKind().(*structpb.Value_StringValue)
+		if !ok || swapped {
+			return nil, fmt.Errorf("operation %q: expected a variable and a string value", op)
+		}
+		switch op {
+		case "startsWith":
+			return Query{"prefix": Query{field: s.StringValue}}, nil
+		case "endsWith":
+			return wildcard(field, "*"+escapeWildcard(s.StringValue)), nil
+		default:
+			return wildcard(field, "*"+escapeWildcard(s.StringValue)+"*"), nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported operation %q", op)
+	}
+}
+
+// fieldAndValue extracts the field name and the value of a binary operation.
+// swapped is true when the value is the left-hand operand.
+func fieldAndValue(operands []*filterOp, names fieldNames) (field string, value *structpb.Value, swapped bool, err error) {
+	switch l := operands[0].GetNode().(type) {
+	case *filterOpVariable:
+		if r, ok := operands[1].GetNode().(*filterOpValue); ok {
+			return names.field(l.Variable), r.Value, false, nil
+		}
+	case *filterOpValue:
+		if r, ok := operands[1].GetNode().(*filterOpVariable); ok {
+			return names.field(r.Variable), l.Value, true, nil
+		}
+	}
+	return "", nil, false, ErrUnsupportedOperand
+}
+
+func term(op, field string, value *structpb.Value) (Query, error) {
+	switch value.GetKind().(type) {
+	case *structpb.Value_ListValue, *structpb.Value_StructValue:
+		return nil, fmt.Errorf("operation %q: expected a scalar value", op)
+	}
+	return Query{"term": Query{field: value.AsInterface()}}, nil
+}
+
+func exists(field string) Query {
+	return Query{"exists": Query{"field": field}}
+}
+
+// notQuery matches the documents that have the field and do not match q. Like SQL, where a comparison with NULL
+// is NULL and so is its negation, the documents missing the field match neither q nor its negation.
+func notQuery(field string, q Query) Query {
+	return Query{"bool": Query{"must": []any{exists(field)}, "must_not": []any{q}}}
+}
+
+func wildcard(field, pattern string) Query {
+	return Query{"wildcard": Query{field: Query{"value": pattern}}}
+}
+
+func boolQuery(occur string, qs ...any) Query {
+	b := Query{occur: qs}
+	if occur == "should" {
+		b["minimum_should_match"] = 1
+	}
+	return Query{"bool": b}
+}
+
+func isNull(v *structpb.Value) bool {
+	_, ok := v.GetKind().(*structpb.Value_NullValue)
+	return ok
+}
+
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+func escapeWildcard(s string) string {
+	return wildcardEscaper.Replace(s)
+}
+
+// fieldNames maps attribute names to field names, e.g. "ownerId": "owner.id", taking precedence over toESField.
+type fieldNames map[string]string
+
+// field returns the field name of a plan variable.
+func (m fieldNames) field(variable string) string {
+	if s, ok := m[attrName(variable)]; ok {
+		return s
+	}
+	return getFieldName(variable)
+}
+
+func getFieldName(name string) string {
+	name = attrName(name)
+
+	if s, ok := toESField[name]; ok {
+		return s
+	}
+
+	return strcase.ToSnake(name)
+}
+
+// attrName returns the name of the resource attribute a plan variable refers to, e.g. ownerId for R.attr.ownerId.
+func attrName(variable string) string {
+	variable = strings.TrimPrefix(variable, "request.resource.attr.")
+	return strings.TrimPrefix(variable, "R.attr.")
+}
@@ -0,0 +1,140 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+//go:embed testdata/query_plans.yaml
+var yamlBytes []byte
+
+type Test struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+	Query json.RawMessage `json:"query"`
+}
+
+func Test_BuildQuery(t *testing.T) {
+	is := require.New(t)
+	jsonBytes, err := yaml.YAMLToJSON(yamlBytes)
+	is.NoError(err)
+	var tests []Test
+	err = json.Unmarshal(jsonBytes, &tests)
+	is.NoError(err)
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			is := require.New(t)
+			e := new(enginev1.PlanResourcesFilter_Expression_Operand)
+			err := protojson.Unmarshal(tt.Input, e)
+			is.NoError(err)
+			q, err := BuildQuery(e.Node.(*enginev1.PlanResourcesFilter_Expression_Operand_Expression))
+			is.NoError(err)
+			got, err := json.Marshal(q)
+			is.NoError(err)
+			is.JSONEq(string(tt.Query), string(got))
+		})
+	}
+}
+
+func Test_BuildFilterJSON(t *testing.T) {
+	tests := []struct {
+		kind enginev1.PlanResourcesFilter_Kind
+		want string
+	}{
+		{kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED, want: `{"match_all":{}}`},
+		{kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED, want: `{"match_none":{}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind.String(), func(t *testing.T) {
+			is := require.New(t)
+			got, err := BuildFilterJSON(&enginev1.PlanResourcesFilter{Kind: tt.kind})
+			is.NoError(err)
+			is.JSONEq(tt.want, string(got))
+		})
+	}
+}
+
+func Test_BuildQueryUnsupported(t *testing.T) {
+	inputs := []string{
+		`{"expression":{"operator":"lt","operands":[{"variable":"a"},{"variable":"b"}]}}`,
+		`{"expression":{"operator":"add","operands":[{"variable":"a"},{"value":1}]}}`,
+		`{"expression":{"operator":"in","operands":[{"variable":"a"},{"value":1}]}}`,
+	}
+	for _, input := range inputs {
+		is := require.New(t)
+		e := new(enginev1.PlanResourcesFilter_Expression_Operand)
+		is.NoError(protojson.Unmarshal([]byte(input), e))
+		_, err := BuildQuery(e.Node.(*enginev1.PlanResourcesFilter_Expression_Operand_Expression))
+		is.Error(err, input)
+	}
+}
+
+// Test_NegationMissingField checks that not(eq) and ne match the same documents, none of which miss the field.
+func Test_NegationMissingField(t *testing.T) {
+	docs := []map[string]any{{"level": 3.0}, {"level": 4.0}, {}}
+	for _, input := range []string{
+		`{"expression":{"operator":"not","operands":[{"expression":{"operator":"eq","operands":[{"variable":"R.attr.level"},{"value":3}]}}]}}`,
+		`{"expression":{"operator":"ne","operands":[{"variable":"R.attr.level"},{"value":3}]}}`,
+		`{"expression":{"operator":"not","operands":[{"expression":{"operator":"not","operands":[
+			{"expression":{"operator":"ne","operands":[{"variable":"R.attr.level"},{"value":3}]}}]}}]}}`,
+	} {
+		is := require.New(t)
+		e := new(enginev1.PlanResourcesFilter_Expression_Operand)
+		is.NoError(protojson.Unmarshal([]byte(input), e))
+		q, err := BuildQuery(e.Node.(*enginev1.PlanResourcesFilter_Expression_Operand_Expression))
+		is.NoError(err)
+		var got []map[string]any
+		for _, doc := range docs {
+			if matches(t, q, doc) {
+				got = append(got, doc)
+			}
+		}
+		is.Equal([]map[string]any{{"level": 4.0}}, got, input)
+	}
+}
+
+// matches evaluates the term, exists and bool queries on a document the way Elasticsearch does.
+func matches(t *testing.T, q Query, doc map[string]any) bool {
+	t.Helper()
+	for kind, body := range q {
+		b := body.(Query)
+		switch kind {
+		case "term":
+			for field, v := range b {
+				return doc[field] == v
+			}
+		case "exists":
+			_, ok := doc[b["field"].(string)]
+			return ok
+		case "bool":
+			res := true
+			must, _ := b["must"].([]any)
+			for _, sub := range must {
+				res = res && matches(t, sub.(Query), doc)
+			}
+			mustNot, _ := b["must_not"].([]any)
+			for _, sub := range mustNot {
+				res = res && !matches(t, sub.(Query), doc)
+			}
+			if should, ok := b["should"].([]any); ok {
+				matched := false
+				for _, sub := range should {
+					matched = matched || matches(t, sub.(Query), doc)
+				}
+				res = res && matched
+			}
+			return res
+		}
+		t.Fatalf("unsupported query %q", kind)
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cerbos/cerbos-go-adapters/internal/planfile"
+)
+
+const usage = `Usage: elastic-adapter <command> [flags]
+
+Commands:
+  query    Print the query DSL of a query plan
+
+Run elastic-adapter <command> -h for the flags of a command.
+`
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+	switch args[0] {
+	case "query":
+		return runQuery(ctx, args[1:], out)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("unknown command %q, run elastic-adapter -h for the list of commands", args[0])
+	}
+}
+
+func runQuery(_ context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: elastic-adapter query [flags] [plan.json|plan.yaml|-]")
+		fmt.Fprintln(fs.Output(), "\nPrints the query DSL of a PlanResourcesResponse or a PlanResourcesFilter read from a file or stdin.")
+		fs.PrintDefaults()
+	}
+	mapping := fs.String("mapping", "", "YAML file mapping attribute names to field names, e.g. ownerId: owner.id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("expected at most one plan file")
+	}
+	var names fieldNames
+	if *mapping != "" {
+		var err error
+		if names, err = planfile.ReadMapping(*mapping); err != nil {
+			return err
+		}
+	}
+	plan, err := planfile.ReadPlan(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	q, err := buildFilter(plan.GetFilter(), names)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", b)
+	return err
+}
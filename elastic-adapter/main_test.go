@@ -0,0 +1,54 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunQuery(t *testing.T) {
+	mapping := filepath.Join(t.TempDir(), "mapping.yaml")
+	require.NoError(t, os.WriteFile(mapping, []byte("ownerId: owner.id\n"), 0o600))
+	owner := `{"filter":{"kind":"KIND_CONDITIONAL","condition":{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.ownerId"},{"value":"2"}]}}}}`
+
+	tests := []struct {
+		args []string
+		plan string
+		want string
+	}{
+		{
+			plan: `{"kind":"KIND_ALWAYS_ALLOWED"}`,
+			want: `{"match_all":{}}`,
+		},
+		{
+			plan: owner,
+			want: `{"term":{"owner_id":"2"}}`,
+		},
+		{
+			args: []string{"-mapping", mapping},
+			plan: owner,
+			want: `{"term":{"owner.id":"2"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			plan := filepath.Join(t.TempDir(), "plan.json")
+			require.NoError(t, os.WriteFile(plan, []byte(tt.plan), 0o600))
+			out := new(bytes.Buffer)
+			require.NoError(t, run(context.Background(), append(append([]string{"query"}, tt.args...), plan), out))
+			require.JSONEq(t, tt.want, out.String())
+		})
+	}
+
+	plan := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(plan, []byte(`{"expression":{"operator":"matches","operands":[{"variable":"request.resource.attr.name"},{"value":"^A"}]}}`), 0o600))
+	require.ErrorContains(t, run(context.Background(), []string{"query", plan}, new(bytes.Buffer)), `unsupported operation "matches"`)
+	require.ErrorIs(t, run(context.Background(), []string{"query", filepath.Join(t.TempDir(), "missing.json")}, new(bytes.Buffer)), os.ErrNotExist)
+}
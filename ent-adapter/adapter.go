@@ -6,11 +6,13 @@ package main
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"entgo.io/ent/dialect/sql"
 	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
 	"github.com/iancoleman/strcase"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 var toSQLOp = map[string]sql.Op{
@@ -34,6 +36,9 @@ var toEntField = map[string]string{
 
 var ErrExpressionExpected = errors.New("expected expression")
 
+// ErrInvalidColumn is returned for a variable mapping to a column name that is not an identifier.
+var ErrInvalidColumn = errors.New("invalid column name")
+
 type filterOpExpression = enginev1.PlanResourcesFilter_Expression_Operand_Expression
 type filterOpValue = enginev1.PlanResourcesFilter_Expression_Operand_Value
 type filterOpVariable = enginev1.PlanResourcesFilter_Expression_Operand_Variable
@@ -45,51 +50,191 @@ func (t BuildPredicateType) BuildPredicate(e *filterOpExpression) (p *sql.Predic
 	return t(e)
 }
 
+// Option configures a predicate builder created with NewPredicateBuilder.
+type Option func(*options)
+
+type options struct {
+	maxDepth       int
+	maxNodes       int
+	maxArgs        int
+	maxListLength  int
+	spillThreshold int
+	selector       *sql.Selector
+	fieldNames     map[string]string
+	principalAttrs map[string]any
+	resolver       Resolver
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{maxDepth: DefaultMaxDepth, maxArgs: MaxPostgresParameters}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSelector qualifies the columns with the table of the selector using s.C, e.g. "c"."owner_id",
+// so that the predicate remains unambiguous when the query joins other tables. See also SelectorPredicate.
+func WithSelector(s *sql.Selector) Option {
+	return func(o *options) {
+		o.selector = s
+	}
+}
+
+// WithFieldNames maps attribute names to column names, e.g. "ownerId": "user_contacts", taking precedence over toEntField.
+func WithFieldNames(names map[string]string) Option {
+	return func(o *options) {
+		o.fieldNames = names
+	}
+}
+
+// fieldName returns the column name of a plan variable, see WithFieldNames and getFieldName.
+func (o *options) fieldName(variable string) string {
+	if s, ok := o.fieldNames[attrName(variable)]; ok {
+		return s
+	}
+	return getFieldName(variable)
+}
+
+// columnName matches the column names written as identifiers, optionally qualified with a table or a schema.
+// ent writes other names, such as names containing quotes or parentheses, as they are, so they are rejected.
+var columnName = regexp.MustCompile(`^[\pL_][\pL\pN_]*(\.[\pL_][\pL\pN_]*)*$`)
+
+// column returns the column name for a plan variable, qualified with the table of the selector if there is one.
+func (o *options) column(variable string) (string, error) {
+	name := o.fieldName(variable)
+	if !columnName.MatchString(name) {
+		return "", fmt.Errorf("%w %q", ErrInvalidColumn, name)
+	}
+	if o.selector != nil {
+		return o.selector.C(name), nil
+	}
+	return name, nil
+}
+
+// NewPredicateBuilder returns a BuildPredicateType configured with the given options.
+func NewPredicateBuilder(opts ...Option) BuildPredicateType {
+	o := newOptions(opts...)
+	return func(e *filterOpExpression) (*sql.Predicate, error) {
+		return o.build(e)
+	}
+}
+
+// SelectorPredicate returns a function for the Where method of an ent query that applies the plan expression
+// with the columns qualified with the table of the query, e.g. client.Contact.Query().Where(SelectorPredicate(e)).
+// Translation errors are added to the selector.
+func SelectorPredicate(e *filterOpExpression, opts ...Option) func(*sql.Selector) {
+	return func(s *sql.Selector) {
+		p, err := NewPredicateBuilder(append(opts[:len(opts):len(opts)], WithSelector(s))...).BuildPredicate(e)
+		if err != nil {
+			s.AddError(err)
+			return
+		}
+		if p != nil {
+			s.Where(p)
+		}
+	}
+}
+
 func BuildPredicate(e *filterOpExpression) (p *sql.Predicate, err error) {
+	return newOptions().build(e)
+}
+
+func (o *options) build(e *filterOpExpression) (*sql.Predicate, error) {
+	if e == nil {
+		return nil, nil
+	}
+	if err := (&limiter{options: o}).checkPlan(e, 1); err != nil {
+		return nil, err
+	}
+	e, err := o.resolve(e)
+	if err != nil {
+		return nil, err
+	}
+	return buildPredicate(e, &limiter{options: o}, 1)
+}
+
+func buildPredicate(e *filterOpExpression, l *limiter, depth int) (p *sql.Predicate, err error) {
 	if e == nil {
 		return nil, nil
 	}
-	switch e.Expression.Operator {
+	if err = l.visit(depth); err != nil {
+		return nil, expressionError(CategoryLimitExceeded, e, err)
+	}
+	operator := e.Expression.Operator
+	switch operator {
 	case "or", "and":
-		ps := make([]*sql.Predicate, len(e.Expression.Operands))
-		for i, o := range e.Expression.Operands {
+		operands := e.Expression.Operands
+		var index []int
+		if operator == "or" {
+			operands, index = mergeEqualities(operands, l.options)
+		}
+		ps := make([]*sql.Predicate, len(operands))
+		for i, o := range operands {
+			pos := i
+			if index != nil {
+				// Merging shifts the operands, the errors refer to their position in the plan.
+				pos = index[i]
+			}
 			if oe, ok := o.GetNode().(*filterOpExpression); ok {
-				ps[i], err = BuildPredicate(oe)
+				ps[i], err = buildPredicate(oe, l, depth+1)
 				if err != nil {
-					return nil, err
+					return nil, inOperand(err, operator, pos)
 				}
 			} else {
-				return nil, ErrExpressionExpected
+				return nil, inOperand(operandError(CategoryMalformed, "", ErrExpressionExpected), operator, pos)
 			}
 		}
-		if e.Expression.Operator == "or" {
+		if operator == "or" {
 			return sql.Or(ps...), nil
 		}
 		return sql.And(ps...), nil
 	case "not":
+		if len(e.Expression.Operands) != 1 {
+			return nil, expressionError(CategoryMalformed, e, fmt.Errorf("expected a unary operation: op = %q, # of operands = %d", operator, len(e.Expression.Operands)))
+		}
 		o := e.Expression.Operands[0]
 		if oe, ok := o.GetNode().(*filterOpExpression); ok {
-			p, err = BuildPredicate(oe)
+			p, err = buildPredicate(oe, l, depth+1)
 			if err != nil {
-				return nil, err
+				return nil, inOperand(err, operator, 0)
 			}
 			return sql.Not(p), nil
 		}
-		return nil, ErrExpressionExpected
+		return nil, inOperand(operandError(CategoryMalformed, "", ErrExpressionExpected), operator, 0)
 	default:
 		const numOperands = 2
 		if len(e.Expression.Operands) != numOperands {
-			return nil, fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+			return nil, expressionError(CategoryMalformed, e, fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", operator, len(e.Expression.Operands)))
 		}
-		op, ok := toSQLOp[e.Expression.Operator]
+		op, ok := toSQLOp[operator]
 		if !ok {
-			return nil, fmt.Errorf("unsupported operation %q", e.Expression.Operator)
+			return nil, expressionError(CategoryUnsupported, e, fmt.Errorf("unsupported operation %q", operator))
+		}
+		for i, operand := range e.Expression.Operands {
+			if _, ok := operand.Node.(*filterOpExpression); ok {
+				continue
+			}
+			if err = l.visit(depth + 1); err != nil {
+				return nil, inOperand(operandError(CategoryLimitExceeded, operandKind(operand), err), operator, i)
+			}
+			if v, ok := operand.Node.(*filterOpValue); ok {
+				if err = l.bind(v.Value.AsInterface()); err != nil {
+					return nil, inOperand(operandError(CategoryLimitExceeded, "value", err), operator, i)
+				}
+			}
+		}
+		if p, ok, err := buildIn(operator, e.Expression.Operands, l.options); ok || err != nil {
+			return p, err
+		}
+		if p, ok, err := buildNullCheck(operator, e.Expression.Operands, l.options); ok || err != nil {
+			return p, err
 		}
 		var args [2]func(builder *sql.Builder) *sql.Builder
 		for i, v := range e.Expression.Operands {
-			args[i], err = newBuilder(v)
+			args[i], err = newBuilder(v, l, depth+1)
 			if err != nil {
-				return nil, err
+				return nil, inOperand(err, operator, i)
 			}
 		}
 		return sql.P().Append(func(b *sql.Builder) {
@@ -100,10 +245,80 @@ func BuildPredicate(e *filterOpExpression) (p *sql.Predicate, err error) {
 	}
 }
 
-func newBuilder(operand *filterOp) (func(*sql.Builder) *sql.Builder, error) {
+// buildIn uses sql.In for a comparison between a column and a list of values,
+// or spills the list into a single parameter if it is longer than the spill threshold.
+func buildIn(operator string, operands []*filterOp, o *options) (*sql.Predicate, bool, error) {
+	if operator != "in" {
+		return nil, false, nil
+	}
+	l, ok := operands[0].GetNode().(*filterOpVariable)
+	if !ok {
+		return nil, false, nil
+	}
+	if _, ok := bindingName(l.Variable); ok {
+		return nil, false, nil
+	}
+	r, ok := operands[1].GetNode().(*filterOpValue)
+	if !ok {
+		return nil, false, nil
+	}
+	values, ok := r.Value.AsInterface().([]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	column, err := o.column(l.Variable)
+	if err != nil {
+		return nil, true, inOperand(operandError(CategoryUnmappedAttribute, "variable", err), operator, 0)
+	}
+	if o.spill(len(values)) {
+		p, err := spillIn(column, values)
+		if err != nil {
+			return nil, true, inOperand(operandError(CategoryUnsupported, "value", err), operator, 1)
+		}
+		return p, true, nil
+	}
+	return sql.In(column, values...), true, nil
+}
+
+// buildNullCheck uses IS [NOT] NULL for an equality between a column and null, like the other adapters,
+// since = NULL and <> NULL are never true.
+func buildNullCheck(operator string, operands []*filterOp, o *options) (*sql.Predicate, bool, error) {
+	if operator != "eq" && operator != "ne" {
+		return nil, false, nil
+	}
+	for i, operand := range operands {
+		v, ok := operand.GetNode().(*filterOpVariable)
+		if !ok {
+			continue
+		}
+		if _, ok := bindingName(v.Variable); ok {
+			continue
+		}
+		r, ok := operands[1-i].GetNode().(*filterOpValue)
+		if !ok {
+			continue
+		}
+		if _, ok := r.Value.GetKind().(*structpb.Value_NullValue); !ok {
+			continue
+		}
+		column, err := o.column(v.Variable)
+		if err != nil {
+			return nil, true, inOperand(operandError(CategoryUnmappedAttribute, "variable", err), operator, i)
+		}
+		if operator == "eq" {
+			return sql.IsNull(column), true, nil
+		}
+		return sql.NotNull(column), true, nil
+	}
+	return nil, false, nil
+}
+
+// newBuilder returns the builder of an operand of a binary operation. The errors about the operand itself
+// are located relative to it, the caller completes their path.
+func newBuilder(operand *filterOp, l *limiter, depth int) (func(*sql.Builder) *sql.Builder, error) {
 	switch e := operand.Node.(type) {
 	case *filterOpExpression:
-		p, err := BuildPredicate(e)
+		p, err := buildPredicate(e, l, depth)
 		if err != nil {
 			return nil, err
 		}
@@ -111,20 +326,35 @@ func newBuilder(operand *filterOp) (func(*sql.Builder) *sql.Builder, error) {
 			return b.Join(p)
 		}, nil
 	case *filterOpVariable:
+		if name, ok := bindingName(e.Variable); ok {
+			operand, err := l.resolveBinding(name)
+			if err != nil {
+				return nil, operandError(CategoryUnmappedAttribute, "variable", err)
+			}
+			if !operand.isSQL {
+				return nil, operandError(CategoryUnmappedAttribute, "variable", fmt.Errorf("binding %q: expected an SQL operand", name))
+			}
+			return func(b *sql.Builder) *sql.Builder {
+				return b.WriteString("(" + operand.sql + ")")
+			}, nil
+		}
+		column, err := l.column(e.Variable)
+		if err != nil {
+			return nil, operandError(CategoryUnmappedAttribute, "variable", err)
+		}
 		return func(b *sql.Builder) *sql.Builder {
-			return b.Ident(getFieldName(e.Variable))
+			return b.Ident(column)
 		}, nil
 	case *filterOpValue:
 		return func(b *sql.Builder) *sql.Builder {
 			return b.Arg(e.Value.AsInterface())
 		}, nil
 	}
-	return nil, errors.New("unknown Node type")
+	return nil, operandError(CategoryMalformed, "", errors.New("unknown Node type"))
 }
 
 func getFieldName(name string) string {
-	name = strings.TrimPrefix(name, "request.resource.attr.")
-	name = strings.TrimPrefix(name, "R.attr.")
+	name = attrName(name)
 
 	if s, ok := toEntField[name]; ok {
 		return s
@@ -132,3 +362,9 @@ func getFieldName(name string) string {
 
 	return strcase.ToSnake(name)
 }
+
+// attrName returns the name of the resource attribute a plan variable refers to, e.g. ownerId for R.attr.ownerId.
+func attrName(variable string) string {
+	variable = strings.TrimPrefix(variable, "request.resource.attr.")
+	return strings.TrimPrefix(variable, "R.attr.")
+}
@@ -8,6 +8,7 @@ import (
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"path/filepath"
@@ -17,8 +18,10 @@ import (
 	"time"
 
 	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
 	"github.com/cerbos/cerbos-go-adapters/ent-adapter/db"
 	"github.com/cerbos/cerbos-go-adapters/ent-adapter/ent"
+	"github.com/cerbos/cerbos-go-adapters/internal/cerbostest"
 	"github.com/cerbos/cerbos-sdk-go/cerbos"
 	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
 	"github.com/ghodss/yaml"
@@ -60,6 +63,90 @@ func Test_BuildPredicate(t *testing.T) {
 	}
 }
 
+func Test_SelectorPredicate(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","IT"]}]}}]}}`)
+
+	s := sql.Dialect(dialect.Postgres).Select("*").From(sql.Table("contacts").As("c"))
+	SelectorPredicate(e)(s)
+	q, args := s.Query()
+	is.NoError(s.Err())
+	is.Equal(`SELECT * FROM "contacts" AS "c" WHERE "c"."user_contacts" = $1 AND "c"."department" IN ($2, $3)`, q)
+	is.Equal([]interface{}{"2", "Sales", "IT"}, args)
+
+	s = sql.Dialect(dialect.Postgres).Select("*").From(sql.Table("contacts"))
+	SelectorPredicate(e, WithMaxDepth(1))(s)
+	// ent joins the errors of the selector into a single message, so they cannot be matched with errors.Is.
+	is.ErrorContains(s.Err(), "exceeds the maximum depth")
+}
+
+func Test_InvalidColumn(t *testing.T) {
+	for _, variable := range []string{`R.attr.a"b`, "R.attr.count(*)", "R.attr."} {
+		_, err := BuildPredicate(mustExpression(t, fmt.Sprintf(`{"expression":{"operator":"eq","operands":[{"variable":%q},{"value":1}]}}`, variable)))
+		require.ErrorIs(t, err, ErrInvalidColumn, variable)
+	}
+	_, err := NewPredicateBuilder(WithFieldNames(map[string]string{"ownerId": "owner id"})).BuildPredicate(
+		mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":1}]}}`))
+	require.ErrorIs(t, err, ErrInvalidColumn)
+
+	p, err := BuildPredicate(mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.contacts.owner_id"},{"value":1}]}}`))
+	require.NoError(t, err)
+	require.NotNil(t, p)
+}
+
+// Test_RecordedPlans translates the plans recorded in cerbostest.
+func Test_RecordedPlans(t *testing.T) {
+	plans, err := cerbostest.ReadPlans(cerbostest.RecordedPlans)
+	require.NoError(t, err)
+	for _, p := range plans {
+		filter := p.Response.GetFilter()
+		if filter.GetKind() != enginev1.PlanResourcesFilter_KIND_CONDITIONAL {
+			continue
+		}
+		t.Run(p.Principal.ID, func(t *testing.T) {
+			e, ok := filter.GetCondition().GetNode().(*enginev1.PlanResourcesFilter_Expression_Operand_Expression)
+			require.True(t, ok)
+			_, err := BuildPredicate(e)
+			require.NoError(t, err)
+		})
+	}
+}
+
+var refresh = flag.Bool("refresh", false, "run TestIntegration against a Cerbos container and record its query plans in ../internal/cerbostest/plans.yaml")
+
+// planner returns a client replaying the plans recorded in cerbostest, or with -refresh, a client of a Cerbos container
+// running the policies in cerbos/policies that records the plans in ../internal/cerbostest/plans.yaml at the end of the test.
+func planner(ctx context.Context, t *testing.T) cerbostest.Planner {
+	t.Helper()
+	is := require.New(t)
+	if !*refresh {
+		plans, err := cerbostest.ReadPlans(cerbostest.RecordedPlans)
+		is.NoError(err)
+		return cerbostest.NewReplayer(plans)
+	}
+
+	c, err := cerbos.New(runCerbos(ctx, t), cerbos.WithPlaintext())
+	is.NoError(err)
+	recorder := cerbostest.NewRecorder(c)
+	_, currFile, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("could not detect current file directory")
+	}
+	file := filepath.Join(filepath.Dir(currFile), "..", "internal", "cerbostest", "plans.yaml")
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("Not recording the plans of a failed test in %s", file)
+			return
+		}
+		if err := recorder.Save(file); err != nil {
+			t.Errorf("Failed to record the plans: %v", err)
+		}
+	})
+	return recorder
+}
+
 func runCerbos(ctx context.Context, t *testing.T) string {
 	t.Helper()
 
@@ -139,7 +226,7 @@ func runCerbos(ctx context.Context, t *testing.T) string {
 
 func TestIntegration(t *testing.T) {
 	ctx := context.Background()
-	cerbosAddr := runCerbos(ctx, t)
+	c := planner(ctx, t)
 
 	is := require.New(t)
 	nick, simon, mary, christina, aleks := "Nick", "Simon", "Mary", "Christina", "Aleks"
@@ -166,10 +253,9 @@ func TestIntegration(t *testing.T) {
 		},
 	}
 
-	c, err := cerbos.New(cerbosAddr, cerbos.WithPlaintext())
-	is.NoError(err)
 	repo, err := db.New(BuildPredicateType(BuildPredicate), ent.Log(t.Log), ent.Debug())
 	is.NoError(err)
+	t.Cleanup(func() { repo.Close() })
 	err = repo.SetupDatabase(ctx)
 	is.NoError(err)
 	for _, tt := range tests {
@@ -0,0 +1,115 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnresolvedBinding is wrapped by the BindingError returned when a plan refers to a variable or a global
+// that the Resolver given with WithResolver does not resolve.
+var ErrUnresolvedBinding = errors.New("unresolved binding")
+
+// BindingError names the variable or global a plan refers to, in its long form, e.g. "variables.tenant".
+type BindingError struct {
+	Name string
+}
+
+func (e *BindingError) Error() string {
+	return fmt.Sprintf("%s %q: the plan refers to a policy variable or global, not to a column", ErrUnresolvedBinding, e.Name)
+}
+
+func (e *BindingError) Unwrap() error {
+	return ErrUnresolvedBinding
+}
+
+// Operand is what a Resolver substitutes for a variable or a global: either a value bound as a parameter
+// or an SQL expression.
+type Operand struct {
+	value any
+	sql   string
+	isSQL bool
+}
+
+// ValueOperand returns an Operand bound as a parameter, like a literal value in the plan.
+func ValueOperand(v any) Operand {
+	return Operand{value: v}
+}
+
+// SQLOperand returns an Operand inserted into the generated SQL in parentheses, e.g. current_setting('app.tenant').
+// The SQL is not escaped, so it must never be derived from user input.
+func SQLOperand(sql string) Operand {
+	return Operand{sql: sql, isSQL: true}
+}
+
+// Resolver resolves the variables and globals that Cerbos leaves in a plan. The names are passed in their long form:
+// V.tenant and variables.tenant are both resolved as "variables.tenant", G.env and globals.env as "globals.env".
+type Resolver interface {
+	Resolve(name string) (Operand, bool)
+}
+
+// ResolverFunc is a function implementing Resolver.
+type ResolverFunc func(name string) (Operand, bool)
+
+func (f ResolverFunc) Resolve(name string) (Operand, bool) {
+	return f(name)
+}
+
+// WithResolver resolves the variables.*, V.*, globals.* and G.* names in a plan with r.
+// Without a resolver, a plan referring to any of them fails with a BindingError.
+func WithResolver(r Resolver) Option {
+	return func(o *options) {
+		o.resolver = r
+	}
+}
+
+var bindingPrefixes = []struct {
+	prefix, long string
+}{
+	{prefix: "variables.", long: "variables."},
+	{prefix: "V.", long: "variables."},
+	{prefix: "globals.", long: "globals."},
+	{prefix: "G.", long: "globals."},
+}
+
+// bindingName returns the long form of a variable or a global, or false if the name refers to neither.
+func bindingName(variable string) (string, bool) {
+	for _, p := range bindingPrefixes {
+		if name, ok := strings.CutPrefix(variable, p.prefix); ok {
+			return p.long + name, true
+		}
+	}
+	return "", false
+}
+
+func (o *options) resolveBinding(name string) (Operand, error) {
+	if o.resolver != nil {
+		if operand, ok := o.resolver.Resolve(name); ok {
+			return operand, nil
+		}
+	}
+	return Operand{}, &BindingError{Name: name}
+}
+
+// resolve substitutes the principal attributes and the bindings resolving to values in the plan expression.
+// Bindings resolving to SQL are left in place for newBuilder.
+func (o *options) resolve(e *filterOpExpression) (*filterOpExpression, error) {
+	e, err := resolvePrincipal(e, o.principalAttrs)
+	if err != nil {
+		return nil, err
+	}
+	return substituteVariables(e, func(variable string) (*filterOp, error) {
+		name, ok := bindingName(variable)
+		if !ok {
+			return nil, nil
+		}
+		operand, err := o.resolveBinding(name)
+		if err != nil || operand.isSQL {
+			return nil, err
+		}
+		return valueOperand(fmt.Sprintf("binding %q", name), operand.value)
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"entgo.io/ent/dialect"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Resolver(t *testing.T) {
+	is := require.New(t)
+	resolver := ResolverFunc(func(name string) (Operand, bool) {
+		switch name {
+		case "variables.tenant":
+			return SQLOperand("current_setting('app.tenant')"), true
+		case "globals.regions":
+			return ValueOperand([]any{"EMEA", "APAC"}), true
+		}
+		return Operand{}, false
+	})
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.tenantId"},{"variable":"V.tenant"}]}},
+		{"expression":{"operator":"in","operands":[{"variable":"R.attr.region"},{"variable":"G.regions"}]}}]}}`)
+
+	p, err := NewPredicateBuilder(WithResolver(resolver)).BuildPredicate(e)
+	is.NoError(err)
+	p.SetDialect(dialect.Postgres)
+	q, args := p.Query()
+	is.Equal(`"tenant_id" = (current_setting('app.tenant')) AND "region" IN ($1, $2)`, q)
+	is.Equal([]interface{}{"EMEA", "APAC"}, args)
+
+	_, err = BuildPredicate(e)
+	is.ErrorIs(err, ErrUnresolvedBinding)
+}
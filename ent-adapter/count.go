@@ -0,0 +1,65 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"entgo.io/ent/dialect/sql"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+)
+
+// Query is implemented by the queries generated by ent, e.g. *ent.ContactQuery, whose predicates have the type P.
+type Query[Q any, P ~func(*sql.Selector)] interface {
+	Where(...P) Q
+	Count(context.Context) (int, error)
+	Exist(context.Context) (bool, error)
+}
+
+// Count returns the number of entities of the query matching the plan filter, e.g. Count(ctx, client.Contact.Query(), filter).
+// A plan that is always denied returns 0 without running the query, and a plan that is always allowed adds no predicate.
+func Count[Q Query[Q, P], P ~func(*sql.Selector)](ctx context.Context, q Q, filter *enginev1.PlanResourcesFilter, opts ...Option) (int, error) {
+	q, ok, err := applyFilter(q, filter, opts)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return q.Count(ctx)
+}
+
+// Exists tells whether any entity of the query matches the plan filter, e.g. Exists(ctx, client.Contact.Query(), filter).
+// A plan that is always denied returns false without running the query, and a plan that is always allowed adds no predicate.
+func Exists[Q Query[Q, P], P ~func(*sql.Selector)](ctx context.Context, q Q, filter *enginev1.PlanResourcesFilter, opts ...Option) (bool, error) {
+	q, ok, err := applyFilter(q, filter, opts)
+	if err != nil || !ok {
+		return false, err
+	}
+	return q.Exist(ctx)
+}
+
+// applyFilter adds the predicate of the plan filter to the query. It returns false if the plan is always denied.
+// Translation errors are returned rather than added to the selector.
+func applyFilter[Q Query[Q, P], P ~func(*sql.Selector)](q Q, filter *enginev1.PlanResourcesFilter, opts []Option) (Q, bool, error) {
+	if filter == nil {
+		return q, false, errors.New("\"filter\" is nil")
+	}
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		return q, false, nil
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		return q, true, nil
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		e, ok := filter.Condition.GetNode().(*filterOpExpression)
+		if !ok {
+			return q, false, ErrExpressionExpected
+		}
+		if _, err := newOptions(opts...).build(e); err != nil {
+			return q, false, err
+		}
+		return q.Where(P(SelectorPredicate(e, opts...))), true, nil
+	default:
+		return q, false, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cerbos/cerbos-go-adapters/ent-adapter/db"
+	"github.com/cerbos/cerbos-go-adapters/ent-adapter/ent"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCount(t *testing.T) {
+	ctx := context.Background()
+	var queries []string
+	repo, err := db.New(BuildPredicateType(BuildPredicate), ent.Log(func(args ...any) {
+		queries = append(queries, fmt.Sprint(args...))
+	}), ent.Debug())
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	require.NoError(t, repo.SetupDatabase(ctx))
+
+	conditional := func(input string) *enginev1.PlanResourcesFilter {
+		return &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL, Condition: &filterOp{Node: mustExpression(t, input)}}
+	}
+	tests := []struct {
+		name   string
+		filter *enginev1.PlanResourcesFilter
+		// queries is the number of queries run by the count and the existence checks, with the WHERE clause if where is true.
+		queries int
+		where   bool
+	}{
+		{name: "always allowed", filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED}, queries: 4},
+		{name: "always denied", filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED}},
+		{
+			name:    "owner",
+			filter:  conditional(`{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"1"}]}}`),
+			queries: 4,
+			where:   true,
+		},
+		{
+			name:    "no match",
+			filter:  conditional(`{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"0"}]}}`),
+			queries: 4,
+			where:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			contacts, err := repo.GetContacts(ctx, tt.filter)
+			is.NoError(err)
+
+			queries = nil
+			n, err := repo.CountContacts(ctx, tt.filter)
+			is.NoError(err)
+			is.Equal(len(contacts), n)
+			ok, err := repo.ExistsContacts(ctx, tt.filter)
+			is.NoError(err)
+			is.Equal(len(contacts) > 0, ok)
+
+			n, err = Count(ctx, repo.Ent().Contact.Query(), tt.filter)
+			is.NoError(err)
+			is.Equal(len(contacts), n)
+			ok, err = Exists(ctx, repo.Ent().Contact.Query(), tt.filter)
+			is.NoError(err)
+			is.Equal(len(contacts) > 0, ok)
+
+			is.Len(queries, tt.queries)
+			for _, q := range queries {
+				is.Equal(tt.where, strings.Contains(q, "WHERE"), q)
+			}
+		})
+	}
+}
+
+func TestCountInvalid(t *testing.T) {
+	ctx := context.Background()
+	repo, err := db.New(BuildPredicateType(BuildPredicate))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	filter := &enginev1.PlanResourcesFilter{
+		Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"hasIntersection","operands":[{"variable":"R.attr.tags"},{"value":["a"]}]}}`)},
+	}
+	_, err = Count(ctx, repo.Ent().Contact.Query(), filter)
+	require.ErrorIs(t, err, ErrUnsupported)
+	_, err = Exists(ctx, repo.Ent().Contact.Query(), filter)
+	require.ErrorIs(t, err, ErrUnsupported)
+	_, err = Count(ctx, repo.Ent().Contact.Query(), nil)
+	require.Error(t, err)
+}
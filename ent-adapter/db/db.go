@@ -33,27 +33,54 @@ type Client struct {
 }
 
 func (cli *Client) GetContacts(ctx context.Context, filter *enginev1.PlanResourcesFilter) ([]*ent.Contact, error) {
+	q, ok, err := cli.queryContacts(filter)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return q.All(ctx)
+}
+
+// CountContacts returns the number of contacts matching the plan filter without fetching them.
+func (cli *Client) CountContacts(ctx context.Context, filter *enginev1.PlanResourcesFilter) (int, error) {
+	q, ok, err := cli.queryContacts(filter)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return q.Count(ctx)
+}
+
+// ExistsContacts tells whether any contact matches the plan filter without fetching the contacts.
+func (cli *Client) ExistsContacts(ctx context.Context, filter *enginev1.PlanResourcesFilter) (bool, error) {
+	q, ok, err := cli.queryContacts(filter)
+	if err != nil || !ok {
+		return false, err
+	}
+	return q.Exist(ctx)
+}
+
+// queryContacts returns the query of the contacts matching the plan filter. It returns false if the plan is always denied.
+func (cli *Client) queryContacts(filter *enginev1.PlanResourcesFilter) (*ent.ContactQuery, bool, error) {
 	if filter == nil {
-		return nil, errors.New("\"filter\" is nil")
+		return nil, false, errors.New("\"filter\" is nil")
 	}
 	switch filter.Kind {
 	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
-		return nil, nil
+		return nil, false, nil
 	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
-		return cli.client.Contact.Query().All(ctx)
+		return cli.client.Contact.Query(), true, nil
 	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
 		p, err := cli.predicateBuilder.BuildPredicate(filter.Condition.GetNode().(*enginev1.PlanResourcesFilter_Expression_Operand_Expression))
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		if p == nil {
-			return cli.client.Contact.Query().All(ctx)
+			return cli.client.Contact.Query(), true, nil
 		}
 		return cli.client.Contact.Query().Where(func(s *sql.Selector) {
 			s.Where(p)
-		}).All(ctx)
+		}), true, nil
 	default:
-		return nil, errors.New("unspecified filter kind")
+		return nil, false, errors.New("unspecified filter kind")
 	}
 }
 
@@ -65,6 +92,16 @@ func New(b predicateBuilder, options ...ent.Option) (*Client, error) {
 	return &Client{client: c, predicateBuilder: b}, nil
 }
 
+// Close closes the database. The in-memory database is dropped when all its clients are closed.
+func (cli *Client) Close() error {
+	return cli.client.Close()
+}
+
+// Ent returns the ent client of the client, e.g. to run the queries built by the adapter.
+func (cli *Client) Ent() *ent.Client {
+	return cli.client
+}
+
 func (cli *Client) GetUserByUsername(ctx context.Context, username string) (*ent.User, error) {
 	user, err := cli.client.User.Query().Where(user.UsernameEQ(username)).Only(ctx)
 	if err != nil {
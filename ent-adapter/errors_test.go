@@ -0,0 +1,92 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TranslationError(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		category ErrorCategory
+		sentinel error
+		operator string
+		path     string
+	}{
+		{
+			name: "unsupported operator",
+			input: `{"expression":{"operator":"and","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+				{"expression":{"operator":"hasIntersection","operands":[{"variable":"R.attr.tags"},{"value":["a"]}]}}]}}`,
+			category: CategoryUnsupported,
+			sentinel: ErrUnsupported,
+			operator: "hasIntersection",
+			path:     "expression.operands[1].expression",
+		},
+		{
+			name: "wrong number of operands",
+			input: `{"expression":{"operator":"not","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"3"}]}}]}}`,
+			category: CategoryMalformed,
+			sentinel: ErrMalformedPlan,
+			operator: "not",
+			path:     "expression",
+		},
+		{
+			name:     "expression expected",
+			input:    `{"expression":{"operator":"and","operands":[{"value":true}]}}`,
+			category: CategoryMalformed,
+			sentinel: ErrExpressionExpected,
+			operator: "and",
+			path:     "expression.operands[0]",
+		},
+		{
+			name: "invalid column after merging",
+			input: `{"expression":{"operator":"or","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"1"}]}},
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.count(*)"},{"value":"Sales"}]}}]}}`,
+			category: CategoryUnmappedAttribute,
+			sentinel: ErrInvalidColumn,
+			operator: "eq",
+			path:     "expression.operands[2].expression.operands[0].variable",
+		},
+		{
+			name:     "unresolved principal attribute",
+			input:    `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"variable":"P.attr.id"}]}}`,
+			category: CategoryUnmappedAttribute,
+			sentinel: ErrUnresolvedPrincipalAttribute,
+			operator: "eq",
+			path:     "expression.operands[1].variable",
+		},
+		{
+			name:     "list too long",
+			input:    `{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","IT"]}]}}`,
+			opts:     []Option{WithMaxListLength(1)},
+			category: CategoryLimitExceeded,
+			sentinel: ErrLimitExceeded,
+			operator: "in",
+			path:     "expression.operands[1].value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			_, err := NewPredicateBuilder(tt.opts...).BuildPredicate(mustExpression(t, tt.input))
+			is.ErrorIs(err, tt.sentinel)
+			var te *TranslationError
+			is.True(errors.As(err, &te))
+			is.Equal(tt.category, te.Category)
+			is.Equal(tt.operator, te.Operator)
+			is.Equal(tt.path, te.Path)
+		})
+	}
+}
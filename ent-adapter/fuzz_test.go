@@ -0,0 +1,29 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"entgo.io/ent/dialect"
+
+	"github.com/cerbos/cerbos-go-adapters/internal/fuzzplan"
+)
+
+func FuzzBuildPredicate(f *testing.F) {
+	fuzzplan.AddSeeds(f, yamlBytes)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		e := fuzzplan.Decode(b)
+		if e == nil {
+			return
+		}
+		p, err := BuildPredicate(e)
+		if err != nil || p == nil {
+			return
+		}
+		p.SetDialect(dialect.Postgres)
+		where, args := p.Query()
+		fuzzplan.CheckSQL(t, where, len(args))
+	})
+}
@@ -6,6 +6,9 @@ toolchain go1.25.4
 
 require (
 	entgo.io/ent v0.14.5
+	github.com/cerbos/cerbos-go-adapters/internal/cerbostest v0.0.0-00010101000000-000000000000
+	github.com/cerbos/cerbos-go-adapters/internal/fuzzplan v0.0.0-00010101000000-000000000000
+	github.com/cerbos/cerbos-go-adapters/internal/planfile v0.0.0-00010101000000-000000000000
 	github.com/cerbos/cerbos-sdk-go v0.3.13
 	github.com/cerbos/cerbos/api/genpb v0.52.0
 	github.com/ghodss/yaml v1.0.0
@@ -13,6 +16,7 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/ory/dockertest/v3 v3.12.0
 	github.com/stretchr/testify v1.11.1
+	google.golang.org/grpc v1.80.0
 	google.golang.org/protobuf v1.36.11
 )
 
@@ -116,7 +120,12 @@ require (
 	golang.org/x/text v0.36.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20260420184626-e10c466a9529 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20260414002931-afd174a4e478 // indirect
-	google.golang.org/grpc v1.80.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/cerbos/cerbos-go-adapters/internal/cerbostest => ../internal/cerbostest
+
+replace github.com/cerbos/cerbos-go-adapters/internal/fuzzplan => ../internal/fuzzplan
+
+replace github.com/cerbos/cerbos-go-adapters/internal/planfile => ../internal/planfile
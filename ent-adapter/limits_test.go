@@ -0,0 +1,108 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func mustExpression(t *testing.T, input string) *filterOpExpression {
+	t.Helper()
+
+	e := new(enginev1.PlanResourcesFilter_Expression_Operand)
+	require.NoError(t, protojson.Unmarshal([]byte(input), e))
+	oe, ok := e.Node.(*filterOpExpression)
+	require.True(t, ok, "expected an expression: %s", input)
+	return oe
+}
+
+func Test_Limits(t *testing.T) {
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"not","operands":[
+			{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","IT","Marketing"]}]}}]}}]}}`)
+	tests := []struct {
+		name  string
+		opts  []Option
+		limit string
+	}{
+		{name: "depth", opts: []Option{WithMaxDepth(3)}, limit: "depth"},
+		{name: "nodes", opts: []Option{WithMaxNodes(7)}, limit: "nodes"},
+		{name: "args", opts: []Option{WithMaxArgs(3)}, limit: "args"},
+		{name: "spilled args", opts: []Option{WithMaxArgs(2), WithListSpill(2)}},
+		{name: "list length", opts: []Option{WithMaxListLength(2)}, limit: "list length"},
+		{name: "within limits", opts: []Option{WithMaxDepth(4), WithMaxNodes(8), WithMaxArgs(4), WithMaxListLength(3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			_, err := NewPredicateBuilder(tt.opts...).BuildPredicate(e)
+			if tt.limit == "" {
+				is.NoError(err)
+				return
+			}
+			is.ErrorIs(err, ErrLimitExceeded)
+			var le *LimitError
+			is.True(errors.As(err, &le))
+			is.Equal(tt.limit, le.Limit)
+		})
+	}
+}
+
+func Test_LimitsBeforeRewriting(t *testing.T) {
+	is := require.New(t)
+	// The "or" merges into a single "in", which would take 3 nodes instead of 7.
+	chain := mustExpression(t, `{"expression":{"operator":"or","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.department"},{"value":"Sales"}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.department"},{"value":"IT"}]}}]}}`)
+	_, err := NewPredicateBuilder(WithMaxNodes(6)).BuildPredicate(chain)
+	var le *LimitError
+	is.True(errors.As(err, &le))
+	is.Equal("nodes", le.Limit)
+	_, err = NewPredicateBuilder(WithMaxNodes(7)).BuildPredicate(chain)
+	is.NoError(err)
+
+	// A plan deeper than the default limit is rejected before it is resolved.
+	deep := `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`
+	for i := 0; i < DefaultMaxDepth; i++ {
+		deep = `{"expression":{"operator":"not","operands":[` + deep + `]}}`
+	}
+	_, err = NewPredicateBuilder(WithPrincipalAttrs(map[string]any{})).BuildPredicate(mustExpression(t, deep))
+	is.True(errors.As(err, &le))
+	is.Equal("depth", le.Limit)
+	_, err = NewPredicateBuilder(WithMaxDepth(0)).BuildPredicate(mustExpression(t, deep))
+	is.NoError(err)
+}
+
+func Test_ListSpill(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","IT","Marketing"]}]}}`)
+
+	p, err := NewPredicateBuilder(WithListSpill(2)).BuildPredicate(e)
+	is.NoError(err)
+	p.SetDialect(dialect.Postgres)
+	q, args := p.Query()
+	is.Equal(`"department" = ANY($1)`, q)
+	is.Equal([]interface{}{[]interface{}{"Sales", "IT", "Marketing"}}, args)
+
+	p, err = NewPredicateBuilder(WithListSpill(2)).BuildPredicate(e)
+	is.NoError(err)
+	p.SetDialect(dialect.SQLite)
+	q, args = p.Query()
+	is.Equal("`department` IN (SELECT value FROM json_each(?))", q)
+	is.Equal([]interface{}{`["Sales","IT","Marketing"]`}, args)
+
+	p, err = NewPredicateBuilder(WithListSpill(3)).BuildPredicate(e)
+	is.NoError(err)
+	p.SetDialect(dialect.Postgres)
+	q, args = p.Query()
+	is.Equal(`"department" IN ($1, $2, $3)`, q)
+	is.Len(args, 3)
+}
@@ -0,0 +1,206 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"entgo.io/ent/dialect"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+
+	"github.com/cerbos/cerbos-go-adapters/internal/planfile"
+)
+
+const usage = `Usage: ent-adapter <command> [flags]
+
+Commands:
+  sql      Print the SQL predicate of a query plan
+
+Run ent-adapter <command> -h for the flags of a command.
+`
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+	switch args[0] {
+	case "sql":
+		return runSQL(ctx, args[1:], out)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("unknown command %q, run ent-adapter -h for the list of commands", args[0])
+	}
+}
+
+func runSQL(_ context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sql", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ent-adapter sql [flags] [plan.json|plan.yaml|-]")
+		fmt.Fprintln(fs.Output(), "\nPrints the SQL predicate of a PlanResourcesResponse or a PlanResourcesFilter read from a file or stdin.")
+		fs.PrintDefaults()
+	}
+	sqlDialect := fs.String("dialect", dialect.Postgres, "SQL dialect: postgres, mysql or sqlite3")
+	mapping := fs.String("mapping", "", "YAML file mapping attribute names to column names, e.g. ownerId: user_contacts")
+	inline := fs.Bool("inline", false, "inline the arguments in the SQL instead of printing them separately")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("expected at most one plan file")
+	}
+	switch *sqlDialect {
+	case dialect.Postgres, dialect.MySQL, dialect.SQLite:
+	default:
+		return fmt.Errorf("unsupported dialect %q", *sqlDialect)
+	}
+	var opts []Option
+	if *mapping != "" {
+		names, err := planfile.ReadMapping(*mapping)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, WithFieldNames(names))
+	}
+	plan, err := planfile.ReadPlan(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	filter := plan.GetFilter()
+
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		_, err = fmt.Fprintln(out, "TRUE")
+		return err
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		_, err = fmt.Fprintln(out, "FALSE")
+		return err
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+	default:
+		return fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+	e, ok := filter.Condition.GetNode().(*filterOpExpression)
+	if !ok {
+		return ErrExpressionExpected
+	}
+	p, err := NewPredicateBuilder(opts...).BuildPredicate(e)
+	if err != nil {
+		return err
+	}
+	p.SetDialect(*sqlDialect)
+	query, queryArgs := p.Query()
+	if *inline {
+		if query, err = inlineArgs(query, queryArgs); err != nil {
+			return err
+		}
+		queryArgs = nil
+	}
+	return printSQL(out, query, queryArgs, *sqlDialect == dialect.Postgres)
+}
+
+// printSQL prints the SQL followed by its arguments as SQL comments, labelled $n if the placeholders are numbered,
+// and ?n, the n-th ? placeholder, otherwise.
+func printSQL(out io.Writer, query string, args []interface{}, numbered bool) error {
+	label := "?"
+	if numbered {
+		label = "$"
+	}
+	if _, err := fmt.Fprintln(out, query); err != nil {
+		return err
+	}
+	for i, a := range args {
+		j, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "-- %s%d = %s\n", label, i+1, j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inlineArgs replaces the $n and ? placeholders of the query with the SQL literals of the arguments.
+// It is meant for reading the SQL, the translator never writes placeholders inside literals or identifiers.
+func inlineArgs(query string, args []interface{}) (string, error) {
+	b := new(strings.Builder)
+	next := 0
+	for i := 0; i < len(query); i++ {
+		n := -1
+		switch {
+		case query[i] == '?':
+			n = next
+			next++
+		case query[i] == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			k, err := strconv.Atoi(query[i+1 : j])
+			if err != nil {
+				return "", err
+			}
+			n = k - 1
+			i = j - 1
+		default:
+			b.WriteByte(query[i])
+			continue
+		}
+		if n < 0 || n >= len(args) {
+			return "", fmt.Errorf("placeholder %d out of range", n+1)
+		}
+		s, err := sqlLiteral(args[n])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s)
+	}
+	return b.String(), nil
+}
+
+// sqlLiteral renders a plan value as an SQL literal.
+func sqlLiteral(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if x {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case string:
+		return "'" + strings.ReplaceAll(x, "'", "''") + "'", nil
+	case []any:
+		j, err := json.Marshal(x)
+		if err != nil {
+			return "", err
+		}
+		return "'" + strings.ReplaceAll(string(j), "'", "''") + "'", nil
+	}
+	return "", fmt.Errorf("cannot render %T as an SQL literal", v)
+}
@@ -0,0 +1,56 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunSQL(t *testing.T) {
+	plan := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(plan, []byte(`{"kind":"KIND_CONDITIONAL","condition":{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.lastName"},{"value":"O'Brien"}]}}]}}}`), 0o600))
+	mapping := filepath.Join(t.TempDir(), "mapping.yaml")
+	require.NoError(t, os.WriteFile(mapping, []byte("ownerId: owner_id\n"), 0o600))
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"sql", plan},
+			want: "\"user_contacts\" = $1 AND \"last_name\" = $2\n-- $1 = \"2\"\n-- $2 = \"O'Brien\"\n",
+		},
+		{
+			args: []string{"sql", "-dialect", "sqlite3", plan},
+			want: "`user_contacts` = ? AND `last_name` = ?\n-- ?1 = \"2\"\n-- ?2 = \"O'Brien\"\n",
+		},
+		{
+			args: []string{"sql", "-dialect", "sqlite3", "-inline", plan},
+			want: "`user_contacts` = '2' AND `last_name` = 'O''Brien'\n",
+		},
+		{
+			args: []string{"sql", "-mapping", mapping, "-inline", plan},
+			want: "\"owner_id\" = '2' AND \"last_name\" = 'O''Brien'\n",
+		},
+		{
+			args: []string{"sql", "-inline", plan},
+			want: "\"user_contacts\" = '2' AND \"last_name\" = 'O''Brien'\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			require.NoError(t, run(context.Background(), tt.args, out))
+			require.Equal(t, tt.want, out.String())
+		})
+	}
+}
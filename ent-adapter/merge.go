@@ -0,0 +1,84 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// mergeEqualities replaces the equality comparisons between the same column and a literal among the operands
+// of an "or" with a single "in" comparison, e.g. "dept" = $1 OR "dept" = $2 becomes "dept" IN ($1, $2).
+// NULL literals are never merged and values of different kinds are merged separately, so both forms evaluate
+// the same way under SQL three-valued logic: true if the column matches one of the values, NULL if the column is NULL.
+// The second result holds the position among the given operands of each of the returned ones.
+func mergeEqualities(operands []*filterOp, opts *options) ([]*filterOp, []int) {
+	type group struct {
+		index    int
+		variable *filterOp
+		values   []*structpb.Value
+	}
+	groups := make(map[string]*group)
+	var order []*group
+	res := make([]*filterOp, 0, len(operands))
+	index := make([]int, 0, len(operands))
+	for i, o := range operands {
+		variable, value, ok := equalityOperands(o)
+		if !ok {
+			res = append(res, o)
+			index = append(index, i)
+			continue
+		}
+		key := fmt.Sprintf("%s/%T", opts.fieldName(variable.GetVariable()), value.GetKind())
+		if g, ok := groups[key]; ok {
+			g.values = append(g.values, value)
+			continue
+		}
+		g := &group{index: len(res), variable: variable, values: []*structpb.Value{value}}
+		groups[key] = g
+		order = append(order, g)
+		res = append(res, o)
+		index = append(index, i)
+	}
+	for _, g := range order {
+		if len(g.values) > 1 {
+			list := structpb.NewListValue(&structpb.ListValue{Values: g.values})
+			res[g.index] = &filterOp{
+				Node: &filterOpExpression{
+					Expression: &enginev1.PlanResourcesFilter_Expression{
+						Operator: "in",
+						Operands: []*filterOp{g.variable, {Node: &filterOpValue{Value: list}}},
+					},
+				},
+			}
+		}
+	}
+	return res, index
+}
+
+// equalityOperands returns the variable and the value of a comparison such as eq(R.attr.dept, "Sales"),
+// if the value is a string, a number or a boolean.
+func equalityOperands(o *filterOp) (variable *filterOp, value *structpb.Value, ok bool) {
+	e, ok := o.GetNode().(*filterOpExpression)
+	if !ok || e.Expression.Operator != "eq" || len(e.Expression.Operands) != 2 { //nolint:gomnd
+		return nil, nil, false
+	}
+	for i, operand := range e.Expression.Operands {
+		if _, ok := operand.GetNode().(*filterOpVariable); !ok {
+			continue
+		}
+		v, ok := e.Expression.Operands[1-i].GetNode().(*filterOpValue)
+		if !ok {
+			return nil, nil, false
+		}
+		switch v.Value.GetKind().(type) {
+		case *structpb.Value_StringValue, *structpb.Value_NumberValue, *structpb.Value_BoolValue:
+			return operand, v.Value, true
+		}
+		return nil, nil, false
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,308 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/iancoleman/strcase"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not returned by NextPage for the same sort keys.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortKey is a column of the order of the pages.
+type SortKey struct {
+	// Column is the name of the column or of the resource attribute, e.g. "created_at" or "createdAt".
+	Column string
+	Desc   bool
+}
+
+// Page selects a page of the rows matching a plan.
+type Page struct {
+	// SortKeys order the rows. The columns cannot be NULL and the last one must be unique, e.g. the primary key,
+	// so that every row is on exactly one page.
+	SortKeys []SortKey
+	// Size is the maximum number of rows of the page.
+	Size int
+	// Cursor is the cursor returned by NextPage for the previous page, or empty for the first page.
+	Cursor string
+}
+
+// PageQuery holds the clauses of an ent query returning a page of the rows matching a plan, e.g.
+//
+//	q, err := BuildPageQuery(filter, page)
+//	contacts, err := client.Contact.Query().Where(q.Where).Order(q.Order).Limit(q.Limit).All(ctx)
+//	contacts, next, err := NextPage(contacts, page, opts...)
+type PageQuery struct {
+	// Where applies the plan and selects the rows after the cursor, e.g. ("created_at", "id") > ($2, $3).
+	Where func(*sql.Selector)
+	// Order orders the rows by the sort keys.
+	Order func(*sql.Selector)
+	// Limit is one more than the size of the page, which NextPage uses to tell whether there is a next page.
+	Limit int
+}
+
+// BuildPageQuery returns the clauses selecting a page of the rows matching the plan filter.
+// Sort keys in the same direction are compared as a row, mixed directions are compared column by column.
+// The columns are qualified with the table of the query. Translation errors are returned rather than added to the selector.
+func BuildPageQuery(filter *enginev1.PlanResourcesFilter, page Page, opts ...Option) (*PageQuery, error) {
+	if filter == nil {
+		return nil, errors.New("\"filter\" is nil")
+	}
+	if len(page.SortKeys) == 0 {
+		return nil, errors.New("page: no sort keys")
+	}
+	if page.Size <= 0 {
+		return nil, fmt.Errorf("page: invalid size %d", page.Size)
+	}
+	o := newOptions(opts...)
+	columns := make([]string, len(page.SortKeys))
+	for i, key := range page.SortKeys {
+		column, err := o.column(key.Column)
+		if err != nil {
+			return nil, fmt.Errorf("sort key %q: %w", key.Column, err)
+		}
+		columns[i] = column
+	}
+
+	var where []func(*sql.Selector)
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		where = append(where, func(s *sql.Selector) { s.Where(sql.False()) })
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		e, ok := filter.Condition.GetNode().(*filterOpExpression)
+		if !ok {
+			return nil, ErrExpressionExpected
+		}
+		if _, err := o.build(e); err != nil {
+			return nil, err
+		}
+		where = append(where, SelectorPredicate(e, opts...))
+	default:
+		return nil, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, page.SortKeys)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, func(s *sql.Selector) {
+			s.Where(keysetPredicate(s, page.SortKeys, columns, values))
+		})
+	}
+
+	return &PageQuery{
+		Where: func(s *sql.Selector) {
+			for _, w := range where {
+				w(s)
+			}
+		},
+		Order: func(s *sql.Selector) {
+			for i, key := range page.SortKeys {
+				opt := sql.OrderAsc()
+				if key.Desc {
+					opt = sql.OrderDesc()
+				}
+				sql.OrderByField(columns[i], opt).ToFunc()(s)
+			}
+		},
+		Limit: page.Size + 1,
+	}, nil
+}
+
+// keysetPredicate returns the predicate selecting the rows after the one with the given sort key values.
+func keysetPredicate(s *sql.Selector, keys []SortKey, columns []string, values []any) *sql.Predicate {
+	qualified := make([]string, len(columns))
+	for i, c := range columns {
+		qualified[i] = s.C(c)
+	}
+	after := func(i int) *sql.Predicate {
+		if keys[i].Desc {
+			return sql.LT(qualified[i], values[i])
+		}
+		return sql.GT(qualified[i], values[i])
+	}
+	uniform := true
+	for _, key := range keys[1:] {
+		uniform = uniform && key.Desc == keys[0].Desc
+	}
+	switch {
+	case len(keys) == 1:
+		return after(0)
+	case uniform && keys[0].Desc:
+		return sql.CompositeLT(qualified, values...)
+	case uniform:
+		return sql.CompositeGT(qualified, values...)
+	}
+	// (a > $1) OR (a = $1 AND b < $2) OR (a = $1 AND b = $2 AND c > $3) ...
+	terms := make([]*sql.Predicate, len(keys))
+	for i := range keys {
+		var t []*sql.Predicate
+		for j := 0; j < i; j++ {
+			t = append(t, sql.EQ(qualified[j], values[j]))
+		}
+		terms[i] = sql.And(append(t, after(i))...)
+	}
+	return sql.Or(terms...)
+}
+
+// NextPage removes the extra row requested by PageQuery.Limit from the rows of a page and returns the cursor
+// of the next page, or an empty cursor if there is none. The sort key values are read from the fields of
+// the last row, which is an ent entity such as *ent.Contact, matching the columns with the json tags of the fields.
+// Pass the options given to BuildPageQuery, so that a sort key is read from the column the query orders on.
+func NextPage[T any](rows []T, page Page, opts ...Option) ([]T, string, error) {
+	if len(rows) <= page.Size {
+		return rows, "", nil
+	}
+	rows = rows[:page.Size]
+	o := newOptions(opts...)
+	values := make([]any, len(page.SortKeys))
+	for i, key := range page.SortKeys {
+		// The entity has the column of a qualified name, e.g. owner_id for contacts.owner_id.
+		name := o.fieldName(key.Column)
+		v, err := fieldValue(rows[len(rows)-1], name[strings.LastIndex(name, ".")+1:])
+		if err != nil {
+			return nil, "", fmt.Errorf("sort key %q: %w", key.Column, err)
+		}
+		values[i] = v
+	}
+	cursor, err := encodeCursor(page.SortKeys, values)
+	if err != nil {
+		return nil, "", err
+	}
+	return rows, cursor, nil
+}
+
+// fieldValue returns the value of the field of the struct v, or of the struct it points to, for a column.
+// The column name of a field is the name in its json tag, or its name in snake case.
+func fieldValue(v any, column string) (any, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, errors.New("nil struct pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %s", rv.Kind())
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strcase.ToSnake(f.Name)
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			name, _, _ = strings.Cut(tag, ",")
+		}
+		if name == column {
+			return rv.Field(i).Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("no field for column %q in %s", column, rt)
+}
+
+// cursor is the JSON form of a cursor. Keys are the sort keys the cursor was created for, with a "-" prefix if descending.
+type cursor struct {
+	Keys   []string      `json:"k"`
+	Values []cursorValue `json:"v"`
+}
+
+// cursorValue holds a sort key value along with its type, so that it decodes to the type it was encoded from.
+type cursorValue struct {
+	Time   *time.Time `json:"t,omitempty"`
+	Int    *int64     `json:"i,omitempty"`
+	Float  *float64   `json:"f,omitempty"`
+	String *string    `json:"s,omitempty"`
+	Bool   *bool      `json:"b,omitempty"`
+}
+
+func cursorKeys(keys []SortKey) []string {
+	res := make([]string, len(keys))
+	for i, key := range keys {
+		res[i] = key.Column
+		if key.Desc {
+			res[i] = "-" + key.Column
+		}
+	}
+	return res
+}
+
+func encodeCursor(keys []SortKey, values []any) (string, error) {
+	c := cursor{Keys: cursorKeys(keys), Values: make([]cursorValue, len(values))}
+	for i, v := range values {
+		switch x := v.(type) {
+		case time.Time:
+			c.Values[i].Time = &x
+		case string:
+			c.Values[i].String = &x
+		case bool:
+			c.Values[i].Bool = &x
+		default:
+			rv := reflect.ValueOf(v)
+			switch {
+			case rv.CanInt():
+				n := rv.Int()
+				c.Values[i].Int = &n
+			case rv.CanUint() && rv.Uint() <= 1<<63-1:
+				n := int64(rv.Uint())
+				c.Values[i].Int = &n
+			case rv.CanFloat():
+				f := rv.Float()
+				c.Values[i].Float = &f
+			default:
+				return "", fmt.Errorf("sort key %q: unsupported value %v of type %T", keys[i].Column, v, v)
+			}
+		}
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string, keys []SortKey) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if !slices.Equal(c.Keys, cursorKeys(keys)) || len(c.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: created for the sort keys %v", ErrInvalidCursor, c.Keys)
+	}
+	values := make([]any, len(c.Values))
+	for i, v := range c.Values {
+		switch {
+		case v.Time != nil:
+			values[i] = *v.Time
+		case v.Int != nil:
+			values[i] = *v.Int
+		case v.Float != nil:
+			values[i] = *v.Float
+		case v.String != nil:
+			values[i] = *v.String
+		case v.Bool != nil:
+			values[i] = *v.Bool
+		default:
+			return nil, fmt.Errorf("%w: missing value for sort key %q", ErrInvalidCursor, keys[i].Column)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
+	"github.com/cerbos/cerbos-go-adapters/ent-adapter/db"
+	"github.com/cerbos/cerbos-go-adapters/ent-adapter/ent"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BuildPageQuery(t *testing.T) {
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)
+	byCreatedAt := []SortKey{{Column: "createdAt"}, {Column: "id"}}
+	mixed := []SortKey{{Column: "lastName"}, {Column: "createdAt", Desc: true}, {Column: "id"}}
+	mustCursor := func(keys []SortKey, values ...any) string {
+		c, err := encodeCursor(keys, values)
+		require.NoError(t, err)
+		return c
+	}
+	ownerFilter := &enginev1.PlanResourcesFilter{
+		Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`)},
+	}
+	tests := []struct {
+		name   string
+		filter *enginev1.PlanResourcesFilter
+		page   Page
+		want   string
+		args   []interface{}
+	}{
+		{
+			name:   "first page",
+			filter: ownerFilter,
+			page:   Page{SortKeys: byCreatedAt, Size: 20},
+			want:   `SELECT * FROM "contacts" AS "c" WHERE "c"."user_contacts" = $1 ORDER BY "c"."created_at", "c"."id" LIMIT 21`,
+			args:   []interface{}{"2"},
+		},
+		{
+			name:   "next page",
+			filter: ownerFilter,
+			page:   Page{SortKeys: byCreatedAt, Size: 20, Cursor: mustCursor(byCreatedAt, createdAt, 7)},
+			want: `SELECT * FROM "contacts" AS "c" WHERE "c"."user_contacts" = $1 AND ("c"."created_at", "c"."id") > ($2, $3)` +
+				` ORDER BY "c"."created_at", "c"."id" LIMIT 21`,
+			args: []interface{}{"2", createdAt, int64(7)},
+		},
+		{
+			name:   "descending",
+			filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED},
+			page:   Page{SortKeys: []SortKey{{Column: "id", Desc: true}}, Size: 5, Cursor: mustCursor([]SortKey{{Column: "id", Desc: true}}, 7)},
+			want:   `SELECT * FROM "contacts" AS "c" WHERE "c"."id" < $1 ORDER BY "c"."id" DESC LIMIT 6`,
+			args:   []interface{}{int64(7)},
+		},
+		{
+			name:   "mixed directions",
+			filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED},
+			page:   Page{SortKeys: mixed, Size: 5, Cursor: mustCursor(mixed, "Doe", createdAt, 7)},
+			want: `SELECT * FROM "contacts" AS "c" WHERE "c"."last_name" > $1` +
+				` OR ("c"."last_name" = $2 AND "c"."created_at" < $3)` +
+				` OR ("c"."last_name" = $4 AND "c"."created_at" = $5 AND "c"."id" > $6)` +
+				` ORDER BY "c"."last_name", "c"."created_at" DESC, "c"."id" LIMIT 6`,
+			args: []interface{}{"Doe", "Doe", createdAt, "Doe", createdAt, int64(7)},
+		},
+		{
+			name:   "always denied",
+			filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED},
+			page:   Page{SortKeys: byCreatedAt, Size: 20},
+			want:   `SELECT * FROM "contacts" AS "c" WHERE FALSE ORDER BY "c"."created_at", "c"."id" LIMIT 21`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			q, err := BuildPageQuery(tt.filter, tt.page)
+			is.NoError(err)
+			s := sql.Dialect(dialect.Postgres).Select("*").From(sql.Table("contacts").As("c"))
+			q.Where(s)
+			q.Order(s)
+			s.Limit(q.Limit)
+			query, args := s.Query()
+			is.NoError(s.Err())
+			is.Equal(tt.want, query)
+			is.Equal(tt.args, args)
+		})
+	}
+}
+
+func Test_BuildPageQueryInvalid(t *testing.T) {
+	allowed := &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED}
+	keys := []SortKey{{Column: "createdAt"}, {Column: "id"}}
+	cursor, err := encodeCursor(keys, []any{time.Now(), 1})
+	require.NoError(t, err)
+	tests := []struct {
+		name   string
+		filter *enginev1.PlanResourcesFilter
+		page   Page
+		err    error
+	}{
+		{name: "no sort keys", page: Page{Size: 1}},
+		{name: "no size", page: Page{SortKeys: keys}},
+		{name: "invalid column", page: Page{SortKeys: []SortKey{{Column: "count(*)"}}, Size: 1}, err: ErrInvalidColumn},
+		{name: "garbage cursor", page: Page{SortKeys: keys, Size: 1, Cursor: "not a cursor"}, err: ErrInvalidCursor},
+		{name: "other sort keys", page: Page{SortKeys: keys[1:], Size: 1, Cursor: cursor}, err: ErrInvalidCursor},
+		{name: "other direction", page: Page{SortKeys: []SortKey{{Column: "createdAt", Desc: true}, {Column: "id"}}, Size: 1, Cursor: cursor}, err: ErrInvalidCursor},
+		{
+			name: "unsupported plan",
+			filter: &enginev1.PlanResourcesFilter{
+				Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+				Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"hasIntersection","operands":[{"variable":"R.attr.tags"},{"value":["a"]}]}}`)},
+			},
+			page: Page{SortKeys: keys, Size: 1},
+			err:  ErrUnsupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			if filter == nil {
+				filter = allowed
+			}
+			_, err := BuildPageQuery(filter, tt.page)
+			require.Error(t, err)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func Test_NextPage(t *testing.T) {
+	is := require.New(t)
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)
+	contacts := []*ent.Contact{{ID: 1, CreatedAt: createdAt}, {ID: 2, CreatedAt: createdAt}, {ID: 3, CreatedAt: createdAt}}
+	page := Page{SortKeys: []SortKey{{Column: "createdAt"}, {Column: "id"}}, Size: 2}
+
+	rows, next, err := NextPage(contacts, page)
+	is.NoError(err)
+	is.Equal(contacts[:2], rows)
+	values, err := decodeCursor(next, page.SortKeys)
+	is.NoError(err)
+	is.Equal([]any{createdAt, int64(2)}, values)
+
+	rows, next, err = NextPage(contacts[:2], page)
+	is.NoError(err)
+	is.Equal(contacts[:2], rows)
+	is.Empty(next)
+
+	_, _, err = NextPage(contacts, Page{SortKeys: []SortKey{{Column: "rank"}}, Size: 2})
+	is.Error(err)
+}
+
+func Test_NextPageFieldNames(t *testing.T) {
+	is := require.New(t)
+	opts := []Option{WithFieldNames(map[string]string{"rank": "first_name", "key": "contacts.id"})}
+	contacts := []*ent.Contact{{ID: 1, FirstName: "a"}, {ID: 2, FirstName: "b"}, {ID: 3, FirstName: "c"}}
+	page := Page{SortKeys: []SortKey{{Column: "rank"}, {Column: "key"}}, Size: 2}
+
+	rows, next, err := NextPage(contacts, page, opts...)
+	is.NoError(err)
+	is.Equal(contacts[:2], rows)
+	values, err := decodeCursor(next, page.SortKeys)
+	is.NoError(err)
+	is.Equal([]any{"b", int64(2)}, values)
+
+	// Without the options the cursor would read the columns named after the sort keys, which the entity does not have.
+	_, _, err = NextPage(contacts, page)
+	is.Error(err)
+}
+
+func TestPageIntegration(t *testing.T) {
+	ctx := context.Background()
+	repo, err := db.New(BuildPredicateType(BuildPredicate))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	require.NoError(t, repo.SetupDatabase(ctx))
+	filter := &enginev1.PlanResourcesFilter{
+		Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"or","operands":[
+			{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+			{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"1"}]}}]}}`)},
+	}
+	all, err := repo.GetContacts(ctx, filter)
+	require.NoError(t, err)
+	want := getNames(all)
+	sort.Strings(want)
+
+	fieldNames := WithFieldNames(map[string]string{"rank": "last_name", "key": "id"})
+	for _, tt := range []struct {
+		keys []SortKey
+		opts []Option
+	}{
+		{keys: []SortKey{{Column: "id"}}},
+		{keys: []SortKey{{Column: "lastName", Desc: true}, {Column: "id", Desc: true}}},
+		{keys: []SortKey{{Column: "active"}, {Column: "firstName", Desc: true}, {Column: "id"}}},
+		{keys: []SortKey{{Column: "rank", Desc: true}, {Column: "key"}}, opts: []Option{fieldNames}},
+	} {
+		keys := tt.keys
+		page := Page{SortKeys: keys, Size: 2}
+		var got []string
+		for i := 0; ; i++ {
+			require.Less(t, i, len(want)+1, "too many pages")
+			q, err := BuildPageQuery(filter, page, tt.opts...)
+			require.NoError(t, err)
+			contacts, err := repo.Ent().Contact.Query().Where(q.Where).Order(q.Order).Limit(q.Limit).All(ctx)
+			require.NoError(t, err)
+			contacts, next, err := NextPage(contacts, page, tt.opts...)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(contacts), page.Size)
+			got = append(got, getNames(contacts)...)
+			if next == "" {
+				break
+			}
+			page.Cursor = next
+		}
+		sort.Strings(got)
+		require.Equal(t, want, got, "sort keys %v", keys)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PrincipalAttrs(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"or","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.department"},{"variable":"P.attr.department"}]}},
+		{"expression":{"operator":"in","operands":[{"variable":"request.resource.attr.region"},{"variable":"request.principal.attr.regions"}]}}]}}`)
+
+	p, err := NewPredicateBuilder(WithPrincipalAttrs(map[string]any{
+		"department": "Sales",
+		"regions":    []any{"EMEA", "APAC"},
+	})).BuildPredicate(e)
+	is.NoError(err)
+	p.SetDialect(dialect.Postgres)
+	q, args := p.Query()
+	is.Equal(`"department" = $1 OR "region" IN ($2, $3)`, q)
+	is.Equal([]interface{}{"Sales", "EMEA", "APAC"}, args)
+
+	// Typed slices, e.g. from a decoded principal, are bound the same way.
+	p, err = NewPredicateBuilder(WithPrincipalAttrs(map[string]any{
+		"department": "Sales",
+		"regions":    []string{"EMEA", "APAC"},
+	})).BuildPredicate(e)
+	is.NoError(err)
+	p.SetDialect(dialect.Postgres)
+	q, args = p.Query()
+	is.Equal(`"department" = $1 OR "region" IN ($2, $3)`, q)
+	is.Equal([]interface{}{"Sales", "EMEA", "APAC"}, args)
+
+	_, err = BuildPredicate(e)
+	is.ErrorIs(err, ErrUnresolvedPrincipalAttribute)
+	var pe *PrincipalAttributeError
+	is.True(errors.As(err, &pe))
+	is.Equal("department", pe.Attr)
+}
@@ -0,0 +1,242 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var toSQLOp = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"lt":   "<",
+	"lte":  "<=",
+	"gt":   ">",
+	"gte":  ">=",
+	"add":  "+",
+	"sub":  "-",
+	"mult": "*",
+	"div":  "/",
+	"mod":  "%",
+	"in":   "IN",
+}
+
+// flipOp is used when the value is on the left-hand side of a comparison, e.g. 10 < R.attr.age.
+var flipOp = map[string]string{
+	"eq":  "eq",
+	"ne":  "ne",
+	"lt":  "gt",
+	"lte": "gte",
+	"gt":  "lt",
+	"gte": "lte",
+}
+
+var toSQLField = map[string]string{}
+
+var ErrExpressionExpected = errors.New("expected expression")
+
+type filterOpExpression = enginev1.PlanResourcesFilter_Expression_Operand_Expression
+type filterOpValue = enginev1.PlanResourcesFilter_Expression_Operand_Value
+type filterOpVariable = enginev1.PlanResourcesFilter_Expression_Operand_Variable
+type filterOp = enginev1.PlanResourcesFilter_Expression_Operand
+
+// CerbosFilter returns a GORM scope applying the query plan filter, e.g. db.Scopes(CerbosFilter(filter)).Find(&contacts).
+// Translation errors are added to the returned *gorm.DB.
+func CerbosFilter(filter *enginev1.PlanResourcesFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter == nil {
+			_ = db.AddError(errors.New("\"filter\" is nil"))
+			return db
+		}
+		switch filter.Kind {
+		case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+			return db.Clauses(clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "1 = 0"}}})
+		case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+			return db
+		case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+			e, ok := filter.Condition.GetNode().(*filterOpExpression)
+			if !ok {
+				_ = db.AddError(ErrExpressionExpected)
+				return db
+			}
+			expr, err := BuildExpression(e)
+			if err != nil {
+				_ = db.AddError(err)
+				return db
+			}
+			if expr == nil {
+				return db
+			}
+			return db.Clauses(clause.Where{Exprs: []clause.Expression{expr}})
+		default:
+			_ = db.AddError(fmt.Errorf("unexpected filter.Kind: %s", filter.Kind))
+			return db
+		}
+	}
+}
+
+func BuildExpression(e *filterOpExpression) (expr clause.Expression, err error) {
+	return buildExpression(e, nil)
+}
+
+// buildExpression builds the expression with the columns named by names, see fieldNames.
+func buildExpression(e *filterOpExpression, names fieldNames) (expr clause.Expression, err error) {
+	if e == nil {
+		return nil, nil
+	}
+	switch e.Expression.Operator {
+	case "or", "and":
+		if len(e.Expression.Operands) == 0 {
+			return nil, fmt.Errorf("expected at least one operand: op = %q", e.Expression.Operator)
+		}
+		exprs := make([]clause.Expression, len(e.Expression.Operands))
+		for i, o := range e.Expression.Operands {
+			if oe, ok := o.GetNode().(*filterOpExpression); ok {
+				exprs[i], err = buildExpression(oe, names)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, ErrExpressionExpected
+			}
+		}
+		if e.Expression.Operator == "or" {
+			return clause.Or(exprs...), nil
+		}
+		return clause.And(exprs...), nil
+	case "not":
+		if len(e.Expression.Operands) != 1 {
+			return nil, fmt.Errorf("expected a unary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+		}
+		if oe, ok := e.Expression.Operands[0].GetNode().(*filterOpExpression); ok {
+			expr, err = buildExpression(oe, names)
+			if err != nil {
+				return nil, err
+			}
+			// clause.Not negates the operands of an AND one by one, which is not the negation of the conjunction.
+			if _, ok := expr.(clause.AndConditions); ok {
+				expr = clause.Expr{SQL: "?", Vars: []interface{}{expr}}
+			}
+			return clause.Not(expr), nil
+		}
+		return nil, ErrExpressionExpected
+	default:
+		const numOperands = 2
+		if len(e.Expression.Operands) != numOperands {
+			return nil, fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+		}
+		op, ok := toSQLOp[e.Expression.Operator]
+		if !ok {
+			return nil, fmt.Errorf("unsupported operation %q", e.Expression.Operator)
+		}
+		if expr, ok := buildComparison(e.Expression.Operator, e.Expression.Operands, names); ok {
+			return expr, nil
+		}
+		var vars [2]interface{}
+		sql := "? " + op + " ?"
+		for i, operand := range e.Expression.Operands {
+			switch eo := operand.Node.(type) {
+			case *filterOpExpression:
+				vars[i], err = buildExpression(eo, names)
+				if err != nil {
+					return nil, err
+				}
+			case *filterOpVariable:
+				vars[i] = clause.Column{Name: names.column(eo.Variable)}
+			case *filterOpValue:
+				vars[i] = eo.Value.AsInterface()
+			default:
+				return nil, errors.New("unknown Node type")
+			}
+		}
+		if _, ok := vars[0].(clause.Expression); ok {
+			sql = "(?) " + op + " ?"
+		}
+		if _, ok := vars[1].(clause.Expression); ok || op == "IN" {
+			sql = strings.TrimSuffix(sql, "?") + "(?)"
+		}
+		return clause.Expr{SQL: sql, Vars: vars[:]}, nil
+	}
+}
+
+// buildComparison builds the GORM comparison clauses for a comparison between a column and a value.
+func buildComparison(operator string, operands []*filterOp, names fieldNames) (clause.Expression, bool) {
+	var column clause.Column
+	var value interface{}
+	switch l := operands[0].GetNode().(type) {
+	case *filterOpVariable:
+		r, ok := operands[1].GetNode().(*filterOpValue)
+		if !ok {
+			return nil, false
+		}
+		column, value = clause.Column{Name: names.column(l.Variable)}, r.Value.AsInterface()
+	case *filterOpValue:
+		r, ok := operands[1].GetNode().(*filterOpVariable)
+		if !ok {
+			return nil, false
+		}
+		if operator, ok = flipOp[operator]; !ok {
+			return nil, false
+		}
+		column, value = clause.Column{Name: names.column(r.Variable)}, l.Value.AsInterface()
+	default:
+		return nil, false
+	}
+	if _, ok := value.([]interface{}); ok && operator != "in" {
+		return nil, false
+	}
+	switch operator {
+	case "eq":
+		return clause.Eq{Column: column, Value: value}, true
+	case "ne":
+		return clause.Neq{Column: column, Value: value}, true
+	case "lt":
+		return clause.Lt{Column: column, Value: value}, true
+	case "lte":
+		return clause.Lte{Column: column, Value: value}, true
+	case "gt":
+		return clause.Gt{Column: column, Value: value}, true
+	case "gte":
+		return clause.Gte{Column: column, Value: value}, true
+	case "in":
+		if values, ok := value.([]interface{}); ok {
+			return clause.IN{Column: column, Values: values}, true
+		}
+	}
+	return nil, false
+}
+
+// fieldNames maps attribute names to column names, e.g. "ownerId": "owner_id", taking precedence over toSQLField.
+type fieldNames map[string]string
+
+// column returns the column name of a plan variable.
+func (m fieldNames) column(variable string) string {
+	if s, ok := m[attrName(variable)]; ok {
+		return s
+	}
+	return getFieldName(variable)
+}
+
+func getFieldName(name string) string {
+	name = attrName(name)
+
+	if s, ok := toSQLField[name]; ok {
+		return s
+	}
+
+	return strcase.ToSnake(name)
+}
+
+// attrName returns the name of the resource attribute a plan variable refers to, e.g. ownerId for R.attr.ownerId.
+func attrName(variable string) string {
+	variable = strings.TrimPrefix(variable, "request.resource.attr.")
+	return strings.TrimPrefix(variable, "R.attr.")
+}
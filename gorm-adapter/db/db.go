@@ -0,0 +1,115 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+//go:embed seed.json
+var seed []byte
+
+type Client struct {
+	client *gorm.DB
+}
+
+// GetContacts returns the contacts matching the scopes, e.g. GetContacts(ctx, CerbosFilter(filter)).
+func (cli *Client) GetContacts(ctx context.Context, scopes ...func(*gorm.DB) *gorm.DB) (res []*Contact, err error) {
+	err = cli.client.WithContext(ctx).Scopes(scopes...).Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func New(url string, opts ...gorm.Option) (*Client, error) {
+	c, err := gorm.Open(postgres.Open(url), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{client: c}, nil
+}
+
+func (cli *Client) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	user := new(User)
+	err := cli.client.WithContext(ctx).Where(&User{Username: username}).First(user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+//go:embed schema.sql
+var schemaSQL string
+
+// SetupDatabase creates the schema and loads the seed data. The schema script sets the search path,
+// so everything runs in a single transaction to keep to the same connection.
+func SetupDatabase(ctx context.Context, db *gorm.DB) error {
+	var data []Seed
+	err := json.Unmarshal(seed, &data)
+	if err != nil {
+		return fmt.Errorf("failed to read from seed file: %w", err)
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(schemaSQL).Error; err != nil {
+			return fmt.Errorf("failed creating schema: %w", err)
+		}
+		for _, u := range data {
+			user := &User{Name: u.Name, Username: u.Username, Email: u.Email, Role: u.Role, Department: u.Department}
+			if err := tx.Create(user).Error; err != nil {
+				return fmt.Errorf("failed creating a user: %w", err)
+			}
+			for _, c := range u.Contacts {
+				var cid *int
+				if c.Company != nil {
+					company := &Company{Name: c.Company.Name}
+					if err := tx.Create(company).Error; err != nil {
+						return fmt.Errorf("failed creating a company: %w", err)
+					}
+					cid = &company.ID
+				}
+				contact := &Contact{
+					FirstName:      c.FirstName,
+					LastName:       c.LastName,
+					OwnerID:        user.ID,
+					CompanyID:      cid,
+					Active:         c.Active,
+					MarketingOptIn: c.MarketingOptIn,
+				}
+				if err := tx.Create(contact).Error; err != nil {
+					return fmt.Errorf("failed creating a contact: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+type Seed struct {
+	Name       string `json:"name"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	Department string `json:"department"`
+	Contacts   []struct {
+		Company *struct {
+			Name string `json:"name"`
+		} `json:"company"`
+		FirstName      string `json:"firstName"`
+		LastName       string `json:"lastName"`
+		MarketingOptIn bool   `json:"marketingOptIn"`
+		Active         bool   `json:"active"`
+	} `json:"contacts,omitempty"`
+}
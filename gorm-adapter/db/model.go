@@ -0,0 +1,36 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package db
+
+import (
+	"time"
+)
+
+type Contact struct {
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	CompanyID      *int
+	FirstName      string
+	LastName       string
+	ID             int
+	OwnerID        int
+	Active         bool
+	MarketingOptIn bool
+}
+
+type Company struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+	ID        int
+}
+
+type User struct {
+	Username   string
+	Email      string
+	Name       string
+	Role       string
+	Department string
+	ID         int
+}
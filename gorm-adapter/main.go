@@ -0,0 +1,143 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/cerbos/cerbos-go-adapters/internal/planfile"
+)
+
+const usage = `Usage: gorm-adapter <command> [flags]
+
+Commands:
+  sql      Print the SQL predicate of a query plan
+
+Run gorm-adapter <command> -h for the flags of a command.
+`
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+	switch args[0] {
+	case "sql":
+		return runSQL(ctx, args[1:], out)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("unknown command %q, run gorm-adapter -h for the list of commands", args[0])
+	}
+}
+
+func runSQL(_ context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sql", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gorm-adapter sql [flags] [plan.json|plan.yaml|-]")
+		fmt.Fprintln(fs.Output(), "\nPrints the SQL predicate of a PlanResourcesResponse or a PlanResourcesFilter read from a file or stdin.")
+		fs.PrintDefaults()
+	}
+	sqlDialect := fs.String("dialect", "postgres", "SQL dialect: postgres")
+	mapping := fs.String("mapping", "", "YAML file mapping attribute names to column names, e.g. ownerId: owner_id")
+	inline := fs.Bool("inline", false, "inline the arguments in the SQL instead of printing them separately")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("expected at most one plan file")
+	}
+	if *sqlDialect != "postgres" {
+		return fmt.Errorf("unsupported dialect %q", *sqlDialect)
+	}
+	var names fieldNames
+	if *mapping != "" {
+		var err error
+		if names, err = planfile.ReadMapping(*mapping); err != nil {
+			return err
+		}
+	}
+	plan, err := planfile.ReadPlan(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	filter := plan.GetFilter()
+
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		_, err = fmt.Fprintln(out, "TRUE")
+		return err
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		_, err = fmt.Fprintln(out, "FALSE")
+		return err
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+	default:
+		return fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+	e, ok := filter.Condition.GetNode().(*filterOpExpression)
+	if !ok {
+		return ErrExpressionExpected
+	}
+	expr, err := buildExpression(e, names)
+	if err != nil {
+		return err
+	}
+	stmt := &gorm.Statement{DB: dryRunDB, Clauses: map[string]clause.Clause{}}
+	expr.Build(stmt)
+	if *inline {
+		_, err = fmt.Fprintln(out, dryRunDB.Dialector.Explain(stmt.SQL.String(), stmt.Vars...))
+		return err
+	}
+	return printSQL(out, stmt.SQL.String(), stmt.Vars)
+}
+
+// dryRunDB renders expressions with the Postgres dialect without connecting to a database.
+var dryRunDB = func() *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		panic(err)
+	}
+	return db
+}()
+
+// printSQL prints the SQL followed by its arguments as SQL comments.
+func printSQL(out io.Writer, query string, args []interface{}) error {
+	if _, err := fmt.Fprintln(out, query); err != nil {
+		return err
+	}
+	for i, a := range args {
+		j, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "-- $%d = %s\n", i+1, j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunSQL(t *testing.T) {
+	plan := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(plan, []byte(`{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.ownerId"},{"value":"2"}]}}`), 0o600))
+	mapping := filepath.Join(t.TempDir(), "mapping.yaml")
+	require.NoError(t, os.WriteFile(mapping, []byte("ownerId: owner\n"), 0o600))
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"sql", plan},
+			want: "\"owner_id\" = $1\n-- $1 = \"2\"\n",
+		},
+		{
+			args: []string{"sql", "-inline", plan},
+			want: "\"owner_id\" = '2'\n",
+		},
+		{
+			args: []string{"sql", "-mapping", mapping, "-inline", plan},
+			want: "\"owner\" = '2'\n",
+		},
+		{
+			args: []string{"sql", "-inline", plan},
+			want: "\"owner_id\" = '2'\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			require.NoError(t, run(context.Background(), tt.args, out))
+			require.Equal(t, tt.want, out.String())
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package cerbostest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/cerbos/cerbos-sdk-go/cerbos"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	responsev1 "github.com/cerbos/cerbos/api/genpb/cerbos/response/v1"
+	"github.com/ghodss/yaml"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNoRecordedPlan is returned by a Replayer for a request without a recorded plan.
+var ErrNoRecordedPlan = errors.New("no recorded plan")
+
+// Planner is the method of the Cerbos client used to compute query plans, implemented by
+// *cerbos.GRPCClient, Recorder and Replayer.
+type Planner interface {
+	PlanResources(ctx context.Context, principal *cerbos.Principal, resource *cerbos.Resource, action string) (*cerbos.PlanResourcesResponse, error)
+}
+
+// Recorder is a Planner recording the plans returned by another one, e.g. a client of a real PDP.
+type Recorder struct {
+	client Planner
+	mu     sync.Mutex
+	index  map[string]int
+	plans  []*Plan
+}
+
+// NewRecorder returns a Recorder of the plans returned by client.
+func NewRecorder(client Planner) *Recorder {
+	return &Recorder{client: client, index: make(map[string]int)}
+}
+
+func (r *Recorder) PlanResources(ctx context.Context, principal *cerbos.Principal, resource *cerbos.Resource, action string) (*cerbos.PlanResourcesResponse, error) {
+	res, err := r.client.PlanResources(ctx, principal, resource, action)
+	if err != nil {
+		return nil, err
+	}
+	recorded := proto.Clone(res.PlanResourcesResponse).(*responsev1.PlanResourcesResponse)
+	// The IDs change on every call and would make every recording differ.
+	recorded.RequestId = ""
+	recorded.CerbosCallId = ""
+	p := &Plan{Principal: newPrincipal(principal.Obj), Resource: resource.Obj.GetKind(), Action: action, Response: recorded}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.index[p.key()]; ok {
+		r.plans[i] = p
+	} else {
+		r.index[p.key()] = len(r.plans)
+		r.plans = append(r.plans, p)
+	}
+	return res, nil
+}
+
+// Plans returns the recorded plans in the order of the first request for each of them.
+func (r *Recorder) Plans() []*Plan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Plan(nil), r.plans...)
+}
+
+// Save writes the recorded plans to file, in the format read by ReadPlans. The file is shared by the adapters,
+// so the plans already in it are kept, apart from those of the requests recorded again.
+func (r *Recorder) Save(file string) error {
+	plans := r.Plans()
+	existing, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		kept, err := ReadPlans(existing)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		r.mu.Lock()
+		for _, p := range kept {
+			if _, ok := r.index[p.key()]; !ok {
+				plans = append(plans, p)
+			}
+		}
+		r.mu.Unlock()
+	}
+	b, err := WritePlans(plans)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0o600)
+}
+
+const planFileHeader = `# Query plans of the policies in the cerbos/policies of the adapters for the users of their db/seed.json.
+# Refresh them from a Cerbos container with: go test -run TestIntegration -refresh, in any of the adapters.
+`
+
+// WritePlans returns the plans in YAML, in the format read by ReadPlans.
+func WritePlans(plans []*Plan) ([]byte, error) {
+	files := make([]planFile, len(plans))
+	for i, p := range plans {
+		res, err := protojson.Marshal(p.Response)
+		if err != nil {
+			return nil, fmt.Errorf("plan %d: %w", i, err)
+		}
+		files[i] = planFile{Principal: p.Principal, Resource: p.Resource, Action: p.Action, Response: res}
+	}
+	b, err := json.Marshal(files)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = yaml.JSONToYAML(b); err != nil {
+		return nil, err
+	}
+	return append([]byte(planFileHeader), b...), nil
+}
+
+// Replayer is a Planner returning recorded plans. Requests without a recorded plan fail with ErrNoRecordedPlan.
+type Replayer struct {
+	plans map[string]*responsev1.PlanResourcesResponse
+}
+
+// NewReplayer returns a Replayer of the given plans.
+func NewReplayer(plans []*Plan) *Replayer {
+	r := &Replayer{plans: make(map[string]*responsev1.PlanResourcesResponse, len(plans))}
+	for _, p := range plans {
+		r.plans[p.key()] = p.Response
+	}
+	return r
+}
+
+func (r *Replayer) PlanResources(_ context.Context, principal *cerbos.Principal, resource *cerbos.Resource, action string) (*cerbos.PlanResourcesResponse, error) {
+	res, err := r.plan(newPrincipal(principal.Obj), resource.Obj.GetKind(), action)
+	if err != nil {
+		return nil, err
+	}
+	return &cerbos.PlanResourcesResponse{PlanResourcesResponse: res}, nil
+}
+
+// plan returns a copy of the recorded plan for a request.
+func (r *Replayer) plan(principal Principal, resource, action string) (*responsev1.PlanResourcesResponse, error) {
+	key := planKey(principal, resource, action)
+	res, ok := r.plans[key]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoRecordedPlan, key)
+	}
+	return proto.Clone(res).(*responsev1.PlanResourcesResponse), nil
+}
+
+func newPrincipal(p *enginev1.Principal) Principal {
+	principal := Principal{ID: p.GetId(), Roles: p.GetRoles()}
+	if attr := p.GetAttr(); len(attr) > 0 {
+		principal.Attr = make(map[string]any, len(attr))
+		for k, v := range attr {
+			principal.Attr[k] = v.AsInterface()
+		}
+	}
+	return principal
+}
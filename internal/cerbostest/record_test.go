@@ -0,0 +1,78 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package cerbostest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cerbos/cerbos-sdk-go/cerbos"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_RecordReplay(t *testing.T) {
+	is := require.New(t)
+	ctx := context.Background()
+	plans, err := ReadPlans(RecordedPlans)
+	is.NoError(err)
+
+	recorder := NewRecorder(NewReplayer(plans))
+	for _, p := range plans {
+		principal := cerbos.NewPrincipal(p.Principal.ID).WithRoles(p.Principal.Roles...)
+		for k, v := range p.Principal.Attr {
+			principal = principal.WithAttr(k, v)
+		}
+		_, err := recorder.PlanResources(ctx, principal, cerbos.NewResource(p.Resource, ""), p.Action)
+		is.NoError(err)
+	}
+	b, err := WritePlans(recorder.Plans())
+	is.NoError(err)
+	recorded, err := ReadPlans(b)
+	is.NoError(err)
+	is.Len(recorded, len(plans))
+	for i, p := range recorded {
+		is.Equal(plans[i].Principal, p.Principal)
+		is.True(proto.Equal(plans[i].Response, p.Response))
+	}
+
+	_, err = NewReplayer(recorded).PlanResources(ctx, cerbos.NewPrincipal("5").WithRoles("user"), cerbos.NewResource("contact", ""), "read")
+	is.ErrorIs(err, ErrNoRecordedPlan)
+}
+
+func Test_RecorderSave(t *testing.T) {
+	is := require.New(t)
+	ctx := context.Background()
+	plans, err := ReadPlans(RecordedPlans)
+	is.NoError(err)
+	is.Greater(len(plans), 2)
+
+	// The file has the plans of another adapter, the first of which is recorded again with a different response.
+	file := filepath.Join(t.TempDir(), "plans.yaml")
+	b, err := WritePlans(plans[:2])
+	is.NoError(err)
+	is.NoError(os.WriteFile(file, b, 0o600))
+
+	changed := &Plan{Principal: plans[0].Principal, Resource: plans[0].Resource, Action: plans[0].Action, Response: plans[2].Response}
+	recorder := NewRecorder(NewReplayer([]*Plan{changed}))
+	principal := cerbos.NewPrincipal(changed.Principal.ID).WithRoles(changed.Principal.Roles...)
+	for k, v := range changed.Principal.Attr {
+		principal = principal.WithAttr(k, v)
+	}
+	_, err = recorder.PlanResources(ctx, principal, cerbos.NewResource(changed.Resource, ""), changed.Action)
+	is.NoError(err)
+	is.NoError(recorder.Save(file))
+
+	b, err = os.ReadFile(file)
+	is.NoError(err)
+	saved, err := ReadPlans(b)
+	is.NoError(err)
+	is.Len(saved, 2)
+	is.Equal(plans[0].Principal, saved[0].Principal)
+	is.True(proto.Equal(plans[2].Response, saved[0].Response))
+	is.Equal(plans[1].Principal, saved[1].Principal)
+	is.True(proto.Equal(plans[1].Response, saved[1].Response))
+}
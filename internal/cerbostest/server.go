@@ -0,0 +1,146 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cerbostest runs an in-process Cerbos PDP serving recorded query plans,
+// so that the integration tests of the adapters run without a container runtime.
+package cerbostest
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	requestv1 "github.com/cerbos/cerbos/api/genpb/cerbos/request/v1"
+	responsev1 "github.com/cerbos/cerbos/api/genpb/cerbos/response/v1"
+	svcv1 "github.com/cerbos/cerbos/api/genpb/cerbos/svc/v1"
+	"github.com/ghodss/yaml"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// RecordedPlans are the query plans of the policies in the cerbos/policies of the adapters for the users of their db/seed.json.
+//
+//go:embed plans.yaml
+var RecordedPlans []byte
+
+// Principal identifies the principal of a recorded plan.
+type Principal struct {
+	ID    string         `json:"id"`
+	Roles []string       `json:"roles"`
+	Attr  map[string]any `json:"attr,omitempty"`
+}
+
+// Plan is the response of the PDP to a PlanResources request for a principal, a resource kind and an action.
+type Plan struct {
+	Principal Principal
+	Resource  string
+	Action    string
+	Response  *responsev1.PlanResourcesResponse
+}
+
+type planFile struct {
+	Principal Principal       `json:"principal"`
+	Resource  string          `json:"resource"`
+	Action    string          `json:"action"`
+	Response  json.RawMessage `json:"response"`
+}
+
+// ReadPlans parses plans recorded in YAML or JSON, such as RecordedPlans.
+func ReadPlans(b []byte) ([]*Plan, error) {
+	b, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	var files []planFile
+	if err := json.Unmarshal(b, &files); err != nil {
+		return nil, err
+	}
+	plans := make([]*Plan, len(files))
+	for i, f := range files {
+		res := new(responsev1.PlanResourcesResponse)
+		if err := protojson.Unmarshal(f.Response, res); err != nil {
+			return nil, fmt.Errorf("plan %d: %w", i, err)
+		}
+		plans[i] = &Plan{Principal: f.Principal, Resource: f.Resource, Action: f.Action, Response: res}
+	}
+	return plans, nil
+}
+
+func (p *Plan) key() string {
+	return planKey(p.Principal, p.Resource, p.Action)
+}
+
+// planKey identifies a request regardless of the order of the roles and of the attributes.
+func planKey(principal Principal, resource, action string) string {
+	roles := append([]string(nil), principal.Roles...)
+	sort.Strings(roles)
+	attr, err := json.Marshal(principal.Attr)
+	if err != nil {
+		attr = []byte(fmt.Sprint(principal.Attr))
+	}
+	return fmt.Sprintf("principal %q with roles %s and attributes %s, resource %q, action %q",
+		principal.ID, strings.Join(roles, ","), attr, resource, action)
+}
+
+// Server is a Cerbos PDP answering PlanResources requests with recorded plans over gRPC.
+// Requests without a recorded plan fail with codes.NotFound. Other methods are not implemented.
+type Server struct {
+	svcv1.UnimplementedCerbosServiceServer
+	// Addr is the address of the server, to be used with cerbos.New and cerbos.WithPlaintext.
+	Addr     string
+	replayer *Replayer
+	srv      *grpc.Server
+}
+
+// NewServer starts a Server serving the given plans on a local port.
+func NewServer(plans []*Plan) (*Server, error) {
+	s := &Server{replayer: NewReplayer(plans), srv: grpc.NewServer()}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s.Addr = l.Addr().String()
+	svcv1.RegisterCerbosServiceServer(s.srv, s)
+	go s.srv.Serve(l) //nolint:errcheck
+	return s, nil
+}
+
+// Start starts a Server serving RecordedPlans and stops it at the end of the test.
+func Start(t testing.TB) *Server {
+	t.Helper()
+	plans, err := ReadPlans(RecordedPlans)
+	if err != nil {
+		t.Fatalf("Failed to read the recorded plans: %v", err)
+	}
+	s, err := NewServer(plans)
+	if err != nil {
+		t.Fatalf("Failed to start the Cerbos test server: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.srv.Stop()
+}
+
+func (s *Server) PlanResources(_ context.Context, req *requestv1.PlanResourcesRequest) (*responsev1.PlanResourcesResponse, error) {
+	action := req.GetAction() //nolint:staticcheck
+	if actions := req.GetActions(); len(actions) > 0 {
+		action = strings.Join(actions, ",")
+	}
+	res, err := s.replayer.plan(newPrincipal(req.GetPrincipal()), req.GetResource().GetKind(), action)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	res.RequestId = req.GetRequestId()
+	return res, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package cerbostest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cerbos/cerbos-sdk-go/cerbos"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_Server(t *testing.T) {
+	is := require.New(t)
+	ctx := context.Background()
+	c, err := cerbos.New(Start(t).Addr, cerbos.WithPlaintext())
+	is.NoError(err)
+
+	principal := cerbos.NewPrincipal("2").WithRoles("user").WithAttr("department", "Sales")
+	plan, err := c.PlanResources(ctx, principal, cerbos.NewResource("contact", ""), "read")
+	is.NoError(err)
+	is.Equal(enginev1.PlanResourcesFilter_KIND_CONDITIONAL, plan.GetFilter().GetKind())
+	is.Equal("or", plan.GetFilter().GetCondition().GetExpression().GetOperator())
+
+	principal = cerbos.NewPrincipal("1").WithRoles("admin").WithAttr("department", "IT")
+	plan, err = c.PlanResources(ctx, principal, cerbos.NewResource("contact", ""), "read")
+	is.NoError(err)
+	is.Equal(enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED, plan.GetFilter().GetKind())
+
+	principal = cerbos.NewPrincipal("2").WithRoles("user").WithAttr("department", "IT")
+	_, err = c.PlanResources(ctx, principal, cerbos.NewResource("contact", ""), "read")
+	is.Equal(codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,308 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fuzzplan generates the query plans of the fuzz targets of the adapters from the fuzzer's bytes.
+package fuzzplan
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type filterOpExpression = enginev1.PlanResourcesFilter_Expression_Operand_Expression
+type filterOpValue = enginev1.PlanResourcesFilter_Expression_Operand_Value
+type filterOpVariable = enginev1.PlanResourcesFilter_Expression_Operand_Variable
+type filterOp = enginev1.PlanResourcesFilter_Expression_Operand
+
+// An operand is encoded as a kind byte followed by:
+//   - an expression: an index in fuzzOperators, or 255 followed by the operator as a string,
+//     then the number of operands and the operands;
+//   - a variable: its name as a string;
+//   - a value: a value kind byte followed by the value, see encodeValue;
+//   - an operand without a node: nothing.
+//
+// A string is encoded as its length in a byte followed by its bytes.
+// Every input decodes to a plan: a missing byte reads as zero and strings are truncated.
+const (
+	kindExpression = iota
+	kindVariable
+	kindValue
+	kindEmpty
+	numKinds
+)
+
+const (
+	valueNull = iota
+	valueNumber
+	valueString
+	valueBool
+	valueList
+	valueStruct
+	numValueKinds
+)
+
+const (
+	maxFuzzDepth    = 8
+	maxFuzzOperands = 8
+	otherOperator   = 255
+)
+
+var fuzzOperators = []string{"and", "or", "not", "eq", "ne", "lt", "lte", "gt", "gte", "in", "add", "sub", "mult", "div", "mod"}
+
+type planReader struct {
+	b []byte
+}
+
+func (r *planReader) byte() byte {
+	if len(r.b) == 0 {
+		return 0
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *planReader) string() string {
+	n := min(int(r.byte()), len(r.b))
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+func (r *planReader) operand(depth int) *filterOp {
+	kind := r.byte() % numKinds
+	if kind == kindExpression && depth >= maxFuzzDepth {
+		kind = kindValue
+	}
+	switch kind {
+	case kindExpression:
+		operator := fuzzOperators[0]
+		if i := r.byte(); int(i) < len(fuzzOperators) {
+			operator = fuzzOperators[i]
+		} else if i == otherOperator {
+			operator = r.string()
+		}
+		operands := make([]*filterOp, r.byte()%maxFuzzOperands)
+		for i := range operands {
+			operands[i] = r.operand(depth + 1)
+		}
+		return newExpression(operator, operands...)
+	case kindVariable:
+		return &filterOp{Node: &filterOpVariable{Variable: r.string()}}
+	case kindValue:
+		return &filterOp{Node: &filterOpValue{Value: r.value(depth + 1)}}
+	default:
+		return &filterOp{}
+	}
+}
+
+func (r *planReader) value(depth int) *structpb.Value {
+	kind := r.byte() % numValueKinds
+	if (kind == valueList || kind == valueStruct) && depth >= maxFuzzDepth {
+		kind = valueNull
+	}
+	switch kind {
+	case valueNumber:
+		var b [8]byte
+		for i := range b {
+			b[i] = r.byte()
+		}
+		return structpb.NewNumberValue(math.Float64frombits(binary.BigEndian.Uint64(b[:])))
+	case valueString:
+		return structpb.NewStringValue(r.string())
+	case valueBool:
+		return structpb.NewBoolValue(r.byte()&1 == 1)
+	case valueList:
+		values := make([]*structpb.Value, r.byte()%maxFuzzOperands)
+		for i := range values {
+			values[i] = r.value(depth + 1)
+		}
+		return structpb.NewListValue(&structpb.ListValue{Values: values})
+	case valueStruct:
+		fields := make(map[string]*structpb.Value)
+		for n := r.byte() % maxFuzzOperands; n > 0; n-- {
+			k := r.string()
+			fields[k] = r.value(depth + 1)
+		}
+		return structpb.NewStructValue(&structpb.Struct{Fields: fields})
+	default:
+		return structpb.NewNullValue()
+	}
+}
+
+// Decode returns the expression encoded in b, or nil if b does not start with an expression.
+func Decode(b []byte) *filterOpExpression {
+	e, _ := (&planReader{b: b}).operand(0).GetNode().(*filterOpExpression)
+	return e
+}
+
+func encodeString(b []byte, s string) []byte {
+	if len(s) > math.MaxUint8 {
+		s = s[:math.MaxUint8]
+	}
+	return append(append(b, byte(len(s))), s...)
+}
+
+// Encode appends the encoding of an operand to b, dropping the operands and the list items beyond the
+// maximum and truncating the strings, so that the seed corpus can be built from the plans of the tests.
+func Encode(b []byte, o *filterOp) []byte {
+	switch n := o.GetNode().(type) {
+	case *filterOpExpression:
+		b = append(b, kindExpression)
+		if i, ok := fuzzOperatorIndex[n.Expression.Operator]; ok {
+			b = append(b, byte(i))
+		} else {
+			b = encodeString(append(b, otherOperator), n.Expression.Operator)
+		}
+		operands := n.Expression.Operands
+		if len(operands) >= maxFuzzOperands {
+			operands = operands[:maxFuzzOperands-1]
+		}
+		b = append(b, byte(len(operands)))
+		for _, operand := range operands {
+			b = Encode(b, operand)
+		}
+		return b
+	case *filterOpVariable:
+		return encodeString(append(b, kindVariable), n.Variable)
+	case *filterOpValue:
+		return encodeValue(append(b, kindValue), n.Value)
+	default:
+		return append(b, kindEmpty)
+	}
+}
+
+func encodeValue(b []byte, v *structpb.Value) []byte {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return binary.BigEndian.AppendUint64(append(b, valueNumber), math.Float64bits(k.NumberValue))
+	case *structpb.Value_StringValue:
+		return encodeString(append(b, valueString), k.StringValue)
+	case *structpb.Value_BoolValue:
+		if k.BoolValue {
+			return append(b, valueBool, 1)
+		}
+		return append(b, valueBool, 0)
+	case *structpb.Value_ListValue:
+		values := k.ListValue.GetValues()
+		if len(values) >= maxFuzzOperands {
+			values = values[:maxFuzzOperands-1]
+		}
+		b = append(b, valueList, byte(len(values)))
+		for _, v := range values {
+			b = encodeValue(b, v)
+		}
+		return b
+	case *structpb.Value_StructValue:
+		keys := make([]string, 0, len(k.StructValue.GetFields()))
+		for key := range k.StructValue.GetFields() {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) >= maxFuzzOperands {
+			keys = keys[:maxFuzzOperands-1]
+		}
+		b = append(b, valueStruct, byte(len(keys)))
+		for _, key := range keys {
+			b = encodeValue(encodeString(b, key), k.StructValue.Fields[key])
+		}
+		return b
+	default:
+		return append(b, valueNull)
+	}
+}
+
+var fuzzOperatorIndex = func() map[string]int {
+	index := make(map[string]int, len(fuzzOperators))
+	for i, op := range fuzzOperators {
+		index[op] = i
+	}
+	return index
+}()
+
+// AddSeeds adds the inputs of the test cases of a query_plans.yaml file to the seed corpus of f.
+func AddSeeds(f *testing.F, queryPlans []byte) {
+	jsonBytes, err := yaml.YAMLToJSON(queryPlans)
+	require.NoError(f, err)
+	var tests []struct {
+		Input json.RawMessage `json:"input"`
+	}
+	require.NoError(f, json.Unmarshal(jsonBytes, &tests))
+	for _, tt := range tests {
+		o := new(filterOp)
+		require.NoError(f, protojson.Unmarshal(tt.Input, o))
+		f.Add(Encode(nil, o))
+	}
+}
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// CheckSQL checks that the parentheses of the SQL are balanced and that its placeholders are numbered
+// from 1 to the number of arguments, ignoring quoted identifiers and string literals.
+func CheckSQL(t testing.TB, where string, nargs int) {
+	t.Helper()
+	var unquoted []byte
+	var quote byte
+	for i := 0; i < len(where); i++ {
+		c := where[i]
+		switch {
+		case quote != 0 && c == quote:
+			// A doubled quote is an escaped quote.
+			if i+1 < len(where) && where[i+1] == quote {
+				i++
+				continue
+			}
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		default:
+			unquoted = append(unquoted, c)
+		}
+	}
+	require.Zero(t, quote, "unterminated quote: %s", where)
+
+	depth := 0
+	for _, c := range unquoted {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			require.GreaterOrEqual(t, depth, 0, "unbalanced parentheses: %s", where)
+		}
+	}
+	require.Zero(t, depth, "unbalanced parentheses: %s", where)
+
+	seen := make(map[int]bool)
+	for _, m := range placeholder.FindAllStringSubmatch(string(unquoted), -1) {
+		n, err := strconv.Atoi(m[1])
+		require.NoError(t, err)
+		require.False(t, seen[n], "placeholder $%d appears twice: %s", n, where)
+		require.True(t, n >= 1 && n <= nargs, "placeholder $%d out of range for %d arguments: %s", n, nargs, where)
+		seen[n] = true
+	}
+	require.Len(t, seen, nargs, "%d placeholders for %d arguments: %s", len(seen), nargs, where)
+}
+
+func newExpression(operator string, operands ...*filterOp) *filterOp {
+	return &filterOp{
+		Node: &filterOpExpression{
+			Expression: &enginev1.PlanResourcesFilter_Expression{
+				Operator: operator,
+				Operands: operands,
+			},
+		},
+	}
+}
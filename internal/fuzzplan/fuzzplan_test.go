@@ -0,0 +1,45 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package fuzzplan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_EncodeDecode(t *testing.T) {
+	is := require.New(t)
+	o := new(filterOp)
+	is.NoError(protojson.Unmarshal([]byte(`{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"in","operands":[{"variable":"request.resource.attr.department"},{"value":["Sales",1,true,null]}]}},
+		{"expression":{"operator":"hasIntersection","operands":[{"variable":"request.resource.attr.tags"},{"value":{"a":1.5,"b":"c"}}]}}]}}`), o))
+	e := Decode(Encode(nil, o))
+	is.NotNil(e)
+	is.True(proto.Equal(o, &filterOp{Node: e}))
+
+	// A missing byte reads as zero, the index of "and".
+	is.Equal("and", Decode(nil).Expression.GetOperator())
+	is.Nil(Decode([]byte{kindVariable, 1, 'a'}))
+}
+
+func Test_CheckSQL(t *testing.T) {
+	CheckSQL(t, `("owner_id" = $1) OR ("name" = 'a(''$3')`, 1)
+	CheckSQL(t, `("a" + "b") < $2 AND "c" = $1`, 2)
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte{kindExpression, 0, 2, kindVariable, 1, 'a', kindValue, valueString, 1, 'b'})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		e := Decode(b)
+		if e == nil {
+			return
+		}
+		// Encoding the decoded plan is stable, which keeps the seed corpus meaningful.
+		again := Decode(Encode(nil, &filterOp{Node: e}))
+		require.True(t, proto.Equal(&filterOp{Node: e}, &filterOp{Node: again}))
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package planfile reads the query plans and the field mappings given to the command lines of the adapters.
+package planfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	responsev1 "github.com/cerbos/cerbos/api/genpb/cerbos/response/v1"
+	"github.com/ghodss/yaml"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ReadMapping reads the attribute to column mapping of a YAML file, e.g. ownerId: owner_id.
+func ReadMapping(file string) (map[string]string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]string
+	if err := yaml.Unmarshal(b, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return mapping, nil
+}
+
+// ReadPlan reads a PlanResourcesResponse, a PlanResourcesFilter or a bare condition in protojson or YAML
+// from a file, or from stdin if file is empty or "-". A filter or a condition is returned in an otherwise empty response.
+func ReadPlan(file string) (*responsev1.PlanResourcesResponse, error) {
+	var b []byte
+	var err error
+	if file == "" || file == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b, err = yaml.YAMLToJSON(b); err != nil {
+		return nil, fmt.Errorf("failed to parse the plan: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse the plan: %w", err)
+	}
+	unmarshal := protojson.UnmarshalOptions{DiscardUnknown: true}
+	switch {
+	case fields["filter"] != nil:
+		res := new(responsev1.PlanResourcesResponse)
+		if err := unmarshal.Unmarshal(b, res); err != nil {
+			return nil, fmt.Errorf("failed to parse the plan response: %w", err)
+		}
+		if res.GetFilter() == nil {
+			return nil, errors.New("the plan response has no filter")
+		}
+		return res, nil
+	case fields["kind"] != nil:
+		filter := new(enginev1.PlanResourcesFilter)
+		if err := unmarshal.Unmarshal(b, filter); err != nil {
+			return nil, fmt.Errorf("failed to parse the plan filter: %w", err)
+		}
+		return &responsev1.PlanResourcesResponse{Filter: filter}, nil
+	default:
+		condition := new(enginev1.PlanResourcesFilter_Expression_Operand)
+		if err := unmarshal.Unmarshal(b, condition); err != nil {
+			return nil, fmt.Errorf("failed to parse the plan condition: %w", err)
+		}
+		filter := &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL, Condition: condition}
+		return &responsev1.PlanResourcesResponse{Filter: filter}, nil
+	}
+}
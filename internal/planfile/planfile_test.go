@@ -0,0 +1,88 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package planfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "plan.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func Test_ReadPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		kind    enginev1.PlanResourcesFilter_Kind
+		action  string
+	}{
+		{
+			name:    "response",
+			content: `{"action":"read","filter":{"kind":"KIND_ALWAYS_ALLOWED"},"unknownField":1}`,
+			kind:    enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED,
+			action:  "read",
+		},
+		{
+			name:    "filter",
+			content: "kind: KIND_ALWAYS_DENIED\n",
+			kind:    enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED,
+		},
+		{
+			name: "condition",
+			content: `expression:
+  operator: eq
+  operands:
+    - variable: request.resource.attr.ownerId
+    - value: "2"
+`,
+			kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			res, err := ReadPlan(writeFile(t, tt.content))
+			is.NoError(err)
+			is.Equal(tt.kind, res.GetFilter().GetKind())
+			is.Equal(tt.action, res.GetAction())
+			if tt.kind == enginev1.PlanResourcesFilter_KIND_CONDITIONAL {
+				is.Equal("eq", res.GetFilter().GetCondition().GetExpression().GetOperator())
+			}
+		})
+	}
+}
+
+func Test_ReadPlanErrors(t *testing.T) {
+	is := require.New(t)
+	_, err := ReadPlan(filepath.Join(t.TempDir(), "missing.yaml"))
+	is.ErrorIs(err, os.ErrNotExist)
+
+	_, err = ReadPlan(writeFile(t, "- not a plan\n"))
+	is.ErrorContains(err, "failed to parse the plan")
+
+	_, err = ReadPlan(writeFile(t, `{"action":"read","filter":null}`))
+	is.ErrorContains(err, "no filter")
+
+	_, err = ReadPlan(writeFile(t, `{"kind":"KIND_CONDITIONAL","condition":"eq"}`))
+	is.ErrorContains(err, "failed to parse the plan filter")
+}
+
+func Test_ReadMapping(t *testing.T) {
+	is := require.New(t)
+	mapping, err := ReadMapping(writeFile(t, "ownerId: owner_id\nactive: is_active\n"))
+	is.NoError(err)
+	is.Equal(map[string]string{"ownerId": "owner_id", "active": "is_active"}, mapping)
+
+	_, err = ReadMapping(writeFile(t, "ownerId: [owner_id]\n"))
+	is.ErrorContains(err, "failed to read")
+}
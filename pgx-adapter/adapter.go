@@ -10,6 +10,8 @@ import (
 
 	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
 	"github.com/iancoleman/strcase"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 var toSQLOp = map[string]string{
@@ -31,6 +33,8 @@ var toSQLField = map[string]string{}
 
 var ErrExpressionExpected = errors.New("expected expression")
 
+var ErrColumnNotAllowed = errors.New("column not allowed")
+
 type filterOpExpression = enginev1.PlanResourcesFilter_Expression_Operand_Expression
 type filterOpValue = enginev1.PlanResourcesFilter_Expression_Operand_Value
 type filterOpVariable = enginev1.PlanResourcesFilter_Expression_Operand_Variable
@@ -42,70 +46,107 @@ func (t BuildPredicateType) BuildPredicate(e *filterOpExpression) (where string,
 	return t(e)
 }
 
-func buildPredicateImpl(e *filterOpExpression, b *strings.Builder, args *[]interface{}) (err error) {
-	switch e.Expression.Operator {
+func buildPredicateImpl(e *filterOpExpression, b *strings.Builder, args *[]interface{}, l *limiter, depth int) (err error) {
+	if err = l.visit(depth); err != nil {
+		return expressionError(CategoryLimitExceeded, e, err)
+	}
+	if l.explainer != nil {
+		l.explainer.enter(e, b.Len())
+		defer l.explainer.leave(b)
+	}
+	operator := e.Expression.Operator
+	switch operator {
 	case "or", "and":
 		b.WriteRune('(')
-		op := strings.ToUpper(e.Expression.Operator)
-		n := len(e.Expression.Operands)
-		for i, o := range e.Expression.Operands {
+		op := strings.ToUpper(operator)
+		operands := e.Expression.Operands
+		var index []int
+		if operator == "or" {
+			operands, index = mergeEqualities(operands, l.options)
+		}
+		n := len(operands)
+		for i, o := range operands {
+			pos := i
+			if index != nil {
+				// Merging shifts the operands, the errors refer to their position in the plan.
+				pos = index[i]
+			}
 			if i > 0 && n > 1 {
 				b.WriteRune(' ')
 				b.WriteString(op)
 				b.WriteRune(' ')
 			}
 			if oe, ok := o.GetNode().(*filterOpExpression); ok {
-				err = buildPredicateImpl(oe, b, args)
+				err = buildPredicateImpl(oe, b, args, l, depth+1)
 				if err != nil {
-					return err
+					return inOperand(err, operator, pos)
 				}
 			} else {
-				return ErrExpressionExpected
+				return inOperand(operandError(CategoryMalformed, "", ErrExpressionExpected), operator, pos)
 			}
 		}
 		b.WriteRune(')')
 		return nil
 	case "not":
+		if len(e.Expression.Operands) != 1 {
+			return expressionError(CategoryMalformed, e, fmt.Errorf("expected a unary operation: op = %q, # of operands = %d", operator, len(e.Expression.Operands)))
+		}
 		o := e.Expression.Operands[0]
 		b.WriteRune('(')
 		b.WriteString("NOT ")
 		if oe, ok := o.GetNode().(*filterOpExpression); ok {
-			err = buildPredicateImpl(oe, b, args)
+			err = buildPredicateImpl(oe, b, args, l, depth+1)
 			if err != nil {
-				return err
+				return inOperand(err, operator, 0)
 			}
 			b.WriteRune(')')
 			return nil
 		}
-		return ErrExpressionExpected
+		return inOperand(operandError(CategoryMalformed, "", ErrExpressionExpected), operator, 0)
 	default:
 		if len(e.Expression.Operands) != 2 { //nolint:gomnd
-			return fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+			return expressionError(CategoryMalformed, e, fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", operator, len(e.Expression.Operands)))
 		}
-		op, ok := toSQLOp[e.Expression.Operator]
+		op, ok := toSQLOp[operator]
 		if !ok {
-			return fmt.Errorf("unsupported operation %q", e.Expression.Operator)
+			return expressionError(CategoryUnsupported, e, fmt.Errorf("unsupported operation %q", operator))
+		}
+		if i, ok := nullComparison(operator, e.Expression.Operands); ok {
+			// = NULL and <> NULL are never true, so a comparison with null is written as IS [NOT] NULL like in the other adapters.
+			b.WriteRune('(')
+			if err = buildOperand(e.Expression.Operands[i], b, args, l, depth+1, false); err != nil {
+				return inOperand(err, operator, i)
+			}
+			if err = l.visit(depth + 1); err != nil {
+				return inOperand(operandError(CategoryLimitExceeded, "value", err), operator, 1-i)
+			}
+			if operator == "eq" {
+				b.WriteString(" IS NULL)")
+			} else {
+				b.WriteString(" IS NOT NULL)")
+			}
+			return nil
+		}
+		// A list is passed as a single array parameter, which keeps the statement text independent of its length.
+		var isList bool
+		if r, ok := e.Expression.Operands[1].GetNode().(*filterOpValue); ok {
+			_, isList = r.Value.GetKind().(*structpb.Value_ListValue)
+		}
+		if op == "IN" && isList {
+			op = "= ANY"
 		}
 		b.WriteRune('(')
 		for i, operand := range e.Expression.Operands {
-			switch eo := operand.Node.(type) {
-			case *filterOpExpression:
-				err = buildPredicateImpl(eo, b, args)
-				if err != nil {
-					return err
-				}
-			case *filterOpVariable:
-				b.WriteRune('"')
-				b.WriteString(getFieldName(eo.Variable))
-				b.WriteRune('"')
-			case *filterOpValue:
-				*args = append(*args, eo.Value.AsInterface())
-				b.WriteString(fmt.Sprintf("$%d", len(*args)))
+			if err = buildOperand(operand, b, args, l, depth+1, isList && i == 1); err != nil {
+				return inOperand(err, operator, i)
 			}
 			if i == 0 {
 				b.WriteRune(' ')
 				b.WriteString(op)
-				b.WriteRune(' ')
+				// ANY is written next to its parenthesised array, e.g. = ANY($1).
+				if op != "= ANY" {
+					b.WriteRune(' ')
+				}
 			}
 		}
 		b.WriteRune(')')
@@ -114,12 +155,231 @@ func buildPredicateImpl(e *filterOpExpression, b *strings.Builder, args *[]inter
 	return nil
 }
 
+// nullComparison returns the position of the variable of an equality between a variable and null.
+func nullComparison(operator string, operands []*filterOp) (int, bool) {
+	if operator != "eq" && operator != "ne" {
+		return 0, false
+	}
+	for i, operand := range operands {
+		if _, ok := operand.GetNode().(*filterOpVariable); !ok {
+			continue
+		}
+		if r, ok := operands[1-i].GetNode().(*filterOpValue); ok {
+			if _, ok := r.Value.GetKind().(*structpb.Value_NullValue); ok {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// buildOperand writes an operand of a binary operation. A list on the right-hand side is parenthesised.
+func buildOperand(operand *filterOp, b *strings.Builder, args *[]interface{}, l *limiter, depth int, isList bool) (err error) {
+	if _, ok := operand.Node.(*filterOpExpression); !ok {
+		if err = l.visit(depth); err != nil {
+			return operandError(CategoryLimitExceeded, operandKind(operand), err)
+		}
+	}
+	switch eo := operand.Node.(type) {
+	case *filterOpExpression:
+		return buildPredicateImpl(eo, b, args, l, depth)
+	case *filterOpVariable:
+		column, err := l.column(eo.Variable)
+		if err != nil {
+			return operandError(categoryOf(err), "variable", err)
+		}
+		b.WriteString(column)
+	case *filterOpValue:
+		v := eo.Value.AsInterface()
+		if l.inlineValues {
+			if err = l.bind(0, v); err != nil {
+				return operandError(CategoryLimitExceeded, "value", err)
+			}
+			literal, err := sqlLiteral(v)
+			if err != nil {
+				return operandError(CategoryUnsupported, "value", err)
+			}
+			if isList {
+				literal = "(" + literal + ")"
+			}
+			b.WriteString(literal)
+			return nil
+		}
+		*args = append(*args, v)
+		if err = l.bind(len(*args), v); err != nil {
+			return operandError(CategoryLimitExceeded, "value", err)
+		}
+		if isList {
+			b.WriteString(fmt.Sprintf("($%d)", len(*args)))
+		} else {
+			b.WriteString(fmt.Sprintf("$%d", len(*args)))
+		}
+	default:
+		return operandError(CategoryMalformed, "", errors.New("unknown Node type"))
+	}
+	return nil
+}
+
+// Option configures a predicate builder created with NewPredicateBuilder.
+type Option func(*options)
+
+type options struct {
+	canonicalShape bool
+	simplify       bool
+	maxDepth       int
+	maxNodes       int
+	maxArgs        int
+	maxListLength  int
+	spillThreshold int
+	allowedColumns map[string]struct{}
+	tableAlias     string
+	fieldNames     map[string]string
+	principalAttrs map[string]any
+	resolver       Resolver
+	// inlineValues and principalSettings are set by GenerateRLSPolicy.
+	inlineValues      bool
+	principalSettings map[string]string
+	// explainer is set by Explain.
+	explainer *explainer
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{maxDepth: DefaultMaxDepth, maxArgs: MaxPostgresParameters}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCanonicalShape makes the builder produce the same SQL text for plans of the same shape, i.e. plans that
+// only differ in their literal values or in the order of the operands of "and" and "or".
+// The operands are sorted by shape before the placeholders are numbered, so the text can be used as the key of a
+// prepared statement cache, see ShapeHash.
+func WithCanonicalShape() Option {
+	return func(o *options) {
+		o.canonicalShape = true
+	}
+}
+
+// WithSimplify makes the builder simplify the plan with Simplify before translating it.
+// A plan that folds to a boolean literal is translated to TRUE or FALSE.
+func WithSimplify() Option {
+	return func(o *options) {
+		o.simplify = true
+	}
+}
+
+// WithAllowedColumns restricts the columns that can appear in the generated SQL to the given ones.
+// The names are compared with the mapped column names, e.g. "owner_id" or "cerbforce.contacts.owner_id".
+// A plan referring to any other column fails with ErrColumnNotAllowed.
+func WithAllowedColumns(columns ...string) Option {
+	return func(o *options) {
+		o.allowedColumns = make(map[string]struct{}, len(columns))
+		for _, c := range columns {
+			o.allowedColumns[c] = struct{}{}
+		}
+	}
+}
+
+// WithTableAlias qualifies the columns with the given table name or alias, e.g. "c"."owner_id",
+// so that the predicate remains unambiguous when the query joins other tables.
+// Columns mapped to a qualified name in toSQLField or WithFieldNames are left as they are.
+func WithTableAlias(alias string) Option {
+	return func(o *options) {
+		o.tableAlias = alias
+	}
+}
+
+// WithFieldNames maps attribute names to column names, e.g. "ownerId": "owner_id", taking precedence over toSQLField.
+func WithFieldNames(names map[string]string) Option {
+	return func(o *options) {
+		o.fieldNames = names
+	}
+}
+
+// fieldName returns the column name of a plan variable, see WithFieldNames and getFieldName.
+func (o *options) fieldName(variable string) string {
+	if s, ok := o.fieldNames[attrName(variable)]; ok {
+		return s
+	}
+	return getFieldName(variable)
+}
+
+// column returns the quoted column name for a plan variable, or the SQL of a binding resolved with SQLOperand.
+func (o *options) column(variable string) (string, error) {
+	if name, ok := bindingName(variable); ok {
+		operand, err := o.resolveBinding(name)
+		if err != nil {
+			return "", err
+		}
+		if !operand.isSQL {
+			return "", fmt.Errorf("binding %q: expected an SQL operand", name)
+		}
+		return "(" + operand.sql + ")", nil
+	}
+	if _, ok := principalAttr(variable); ok && o.principalSettings != nil {
+		return o.principalSetting(variable)
+	}
+	name := o.fieldName(variable)
+	if o.allowedColumns != nil {
+		if _, ok := o.allowedColumns[name]; !ok {
+			return "", fmt.Errorf("%w: %q", ErrColumnNotAllowed, name)
+		}
+	}
+	if o.tableAlias != "" && !strings.Contains(name, ".") {
+		return pgx.Identifier{o.tableAlias, name}.Sanitize(), nil
+	}
+	return quoteIdentifier(name), nil
+}
+
+// quoteIdentifier quotes a column name the same way as pgx.Identifier.Sanitize.
+// A name containing dots is schema-qualified, e.g. cerbforce.contacts.owner_id becomes "cerbforce"."contacts"."owner_id".
+func quoteIdentifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+// NewPredicateBuilder returns a BuildPredicateType configured with the given options.
+func NewPredicateBuilder(opts ...Option) BuildPredicateType {
+	o := newOptions(opts...)
+	return func(e *filterOpExpression) (string, []interface{}, error) {
+		return buildPredicate(e, o)
+	}
+}
+
 func BuildPredicate(e *filterOpExpression) (where string, args []interface{}, err error) {
+	return buildPredicate(e, newOptions())
+}
+
+func buildPredicate(e *filterOpExpression, o *options) (where string, args []interface{}, err error) {
 	if e == nil {
 		return "", nil, nil
 	}
+	if err = (&limiter{options: o}).checkPlan(e, 1); err != nil {
+		return "", nil, err
+	}
+	if e, err = o.resolve(e); err != nil || e == nil {
+		return "", nil, err
+	}
+	if o.simplify {
+		s := Simplify(&filterOp{Node: e})
+		if b, ok := boolLiteral(s); ok {
+			if b {
+				return "TRUE", nil, nil
+			}
+			return "FALSE", nil, nil
+		}
+		se, ok := s.GetNode().(*filterOpExpression)
+		if !ok {
+			// e.g. (and R.attr.active), which simplifies to a bare variable.
+			return "", nil, operandError(CategoryMalformed, operandKind(s), ErrExpressionExpected)
+		}
+		e = se
+	}
+	if o.canonicalShape {
+		e = canonicalize(&filterOp{Node: e}, o).GetNode().(*filterOpExpression)
+	}
 	b := new(strings.Builder)
-	err = buildPredicateImpl(e, b, &args)
+	err = buildPredicateImpl(e, b, &args, &limiter{options: o}, 1)
 	where = b.String()
 	n := len(where)
 	if n > 0 && where[0] == '(' && where[n-1] == ')' {
@@ -129,8 +389,7 @@ func BuildPredicate(e *filterOpExpression) (where string, args []interface{}, er
 }
 
 func getFieldName(name string) string {
-	name = strings.TrimPrefix(name, "request.resource.attr.")
-	name = strings.TrimPrefix(name, "R.attr.")
+	name = attrName(name)
 
 	if s, ok := toSQLField[name]; ok {
 		return s
@@ -138,3 +397,9 @@ func getFieldName(name string) string {
 
 	return strcase.ToSnake(name)
 }
+
+// attrName returns the name of the resource attribute a plan variable refers to, e.g. ownerId for R.attr.ownerId.
+func attrName(variable string) string {
+	variable = strings.TrimPrefix(variable, "request.resource.attr.")
+	return strings.TrimPrefix(variable, "R.attr.")
+}
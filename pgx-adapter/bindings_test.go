@@ -0,0 +1,45 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Resolver(t *testing.T) {
+	is := require.New(t)
+	resolver := ResolverFunc(func(name string) (Operand, bool) {
+		switch name {
+		case "variables.tenant":
+			return SQLOperand("current_setting('app.tenant')"), true
+		case "globals.regions":
+			return ValueOperand([]any{"EMEA", "APAC"}), true
+		}
+		return Operand{}, false
+	})
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.tenantId"},{"variable":"V.tenant"}]}},
+		{"expression":{"operator":"in","operands":[{"variable":"R.attr.region"},{"variable":"globals.regions"}]}}]}}`)
+
+	where, args, err := NewPredicateBuilder(WithResolver(resolver)).BuildPredicate(e)
+	is.NoError(err)
+	is.Equal(`("tenant_id" = (current_setting('app.tenant'))) AND ("region" = ANY($1))`, where)
+	is.Equal([]interface{}{[]interface{}{"EMEA", "APAC"}}, args)
+
+	s, err := BuildSqlizer(e, WithResolver(resolver))
+	is.NoError(err)
+	where, args, err = s.ToSql()
+	is.NoError(err)
+	is.Equal(`("tenant_id" = (current_setting('app.tenant')) AND "region" IN (?,?))`, where)
+	is.Equal([]interface{}{"EMEA", "APAC"}, args)
+
+	_, _, err = BuildPredicate(e)
+	is.ErrorIs(err, ErrUnresolvedBinding)
+	var be *BindingError
+	is.True(errors.As(err, &be))
+	is.Equal("variables.tenant", be.Name)
+}
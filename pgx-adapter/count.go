@@ -0,0 +1,97 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/georgysavva/scany/v2/pgxscan"
+)
+
+// BuildCountQuery returns the query counting the rows of from matching the plan filter,
+// e.g. SELECT count(*) FROM contacts WHERE "owner_id" = $1. from is anything that can follow FROM,
+// such as a table name or a join. A plan that is always allowed gives no WHERE clause.
+func BuildCountQuery(from string, filter *enginev1.PlanResourcesFilter, opts ...Option) (string, []interface{}, error) {
+	return buildFilterQuery("SELECT count(*) FROM "+from, filter, newOptions(opts...))
+}
+
+// BuildExistsQuery returns the query telling whether any row of from matches the plan filter,
+// e.g. SELECT EXISTS (SELECT 1 FROM contacts WHERE "owner_id" = $1). See BuildCountQuery.
+func BuildExistsQuery(from string, filter *enginev1.PlanResourcesFilter, opts ...Option) (string, []interface{}, error) {
+	query, args, err := buildFilterQuery("SELECT 1 FROM "+from, filter, newOptions(opts...))
+	if err != nil {
+		return "", nil, err
+	}
+	return "SELECT EXISTS (" + query + ")", args, nil
+}
+
+// Count returns the number of rows of from matching the plan filter using the query of BuildCountQuery.
+// A plan that is always denied returns 0 without running the query.
+func Count(ctx context.Context, db pgxscan.Querier, from string, filter *enginev1.PlanResourcesFilter, opts ...Option) (int64, error) {
+	if filter.GetKind() == enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED {
+		return 0, nil
+	}
+	query, args, err := BuildCountQuery(from, filter, opts...)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if err := pgxscan.Get(ctx, db, &n, query, args...); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Exists tells whether any row of from matches the plan filter using the query of BuildExistsQuery.
+// A plan that is always denied returns false without running the query.
+func Exists(ctx context.Context, db pgxscan.Querier, from string, filter *enginev1.PlanResourcesFilter, opts ...Option) (bool, error) {
+	if filter.GetKind() == enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED {
+		return false, nil
+	}
+	query, args, err := BuildExistsQuery(from, filter, opts...)
+	if err != nil {
+		return false, err
+	}
+	var ok bool
+	if err := pgxscan.Get(ctx, db, &ok, query, args...); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// buildFilterQuery appends the WHERE clause of the plan filter to query.
+func buildFilterQuery(query string, filter *enginev1.PlanResourcesFilter, o *options) (string, []interface{}, error) {
+	where, args, err := filterWhere(filter, o)
+	if err != nil {
+		return "", nil, err
+	}
+	if where != "" {
+		query += " WHERE " + where
+	}
+	return query, args, nil
+}
+
+// filterWhere translates the plan filter: FALSE if it is always denied, and nothing if it is always allowed.
+func filterWhere(filter *enginev1.PlanResourcesFilter, o *options) (string, []interface{}, error) {
+	if filter == nil {
+		return "", nil, errors.New("\"filter\" is nil")
+	}
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		return "FALSE", nil, nil
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		return "", nil, nil
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		e, ok := filter.Condition.GetNode().(*filterOpExpression)
+		if !ok {
+			return "", nil, ErrExpressionExpected
+		}
+		return buildPredicate(e, o)
+	default:
+		return "", nil, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BuildCountQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *enginev1.PlanResourcesFilter
+		count  string
+		exists string
+		args   []interface{}
+	}{
+		{
+			name: "conditional",
+			filter: &enginev1.PlanResourcesFilter{
+				Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+				Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`)},
+			},
+			count:  `SELECT count(*) FROM contacts WHERE "owner_id" = $1`,
+			exists: `SELECT EXISTS (SELECT 1 FROM contacts WHERE "owner_id" = $1)`,
+			args:   []interface{}{"2"},
+		},
+		{
+			name:   "always allowed",
+			filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED},
+			count:  `SELECT count(*) FROM contacts`,
+			exists: `SELECT EXISTS (SELECT 1 FROM contacts)`,
+		},
+		{
+			name:   "always denied",
+			filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED},
+			count:  `SELECT count(*) FROM contacts WHERE FALSE`,
+			exists: `SELECT EXISTS (SELECT 1 FROM contacts WHERE FALSE)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			query, args, err := BuildCountQuery("contacts", tt.filter)
+			is.NoError(err)
+			is.Equal(tt.count, query)
+			is.Equal(tt.args, args)
+			query, args, err = BuildExistsQuery("contacts", tt.filter)
+			is.NoError(err)
+			is.Equal(tt.exists, query)
+			is.Equal(tt.args, args)
+		})
+	}
+
+	_, _, err := BuildCountQuery("contacts", nil)
+	require.Error(t, err)
+}
+
+func TestCountIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires Postgres")
+	}
+	ctx := context.Background()
+	repo := startDatabaseWith(ctx, t, NewPartialPredicateBuilder())
+	conditional := func(input string) *enginev1.PlanResourcesFilter {
+		return &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL, Condition: &filterOp{Node: mustExpression(t, input)}}
+	}
+	filters := map[string]*enginev1.PlanResourcesFilter{
+		"always allowed": {Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED},
+		"always denied":  {Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED},
+		"owner":          conditional(`{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"1"}]}}`),
+		"no match":       conditional(`{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"0"}]}}`),
+		"partial": conditional(`{"expression":{"operator":"and","operands":[
+			{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+			{"expression":{"operator":"startsWith","operands":[{"variable":"R.attr.firstName"},{"value":"N"}]}}]}}`),
+	}
+	for name, filter := range filters {
+		t.Run(name, func(t *testing.T) {
+			is := require.New(t)
+			contacts, err := repo.GetContacts(ctx, filter)
+			is.NoError(err)
+
+			n, err := repo.CountContacts(ctx, filter)
+			is.NoError(err)
+			is.Equal(int64(len(contacts)), n)
+			ok, err := repo.ExistsContacts(ctx, filter)
+			is.NoError(err)
+			is.Equal(len(contacts) > 0, ok)
+
+			if name == "partial" {
+				_, err = Count(ctx, repo.Conn(), "contacts", filter)
+				is.ErrorIs(err, ErrUnsupported)
+				return
+			}
+			n, err = Count(ctx, repo.Conn(), "contacts", filter)
+			is.NoError(err)
+			is.Equal(int64(len(contacts)), n)
+			ok, err = Exists(ctx, repo.Conn(), "contacts", filter)
+			is.NoError(err)
+			is.Equal(len(contacts) > 0, ok)
+		})
+	}
+}
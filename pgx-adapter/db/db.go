@@ -22,6 +22,12 @@ type predicateBuilder interface {
 	BuildPredicate(e *enginev1.PlanResourcesFilter_Expression_Operand_Expression) (where string, args []interface{}, err error)
 }
 
+// partialPredicateBuilder is implemented by the predicate builders able to translate part of a plan only.
+// The rows returned by the query are then filtered with the residual predicate, unless it is nil.
+type partialPredicateBuilder interface {
+	BuildPartialPredicate(e *enginev1.PlanResourcesFilter_Expression_Operand_Expression) (where string, args []interface{}, residual func(any) (bool, error), err error)
+}
+
 type Client struct {
 	client           *pgx.Conn
 	predicateBuilder predicateBuilder
@@ -45,23 +51,115 @@ func (cli *Client) GetContacts(ctx context.Context, filter *enginev1.PlanResourc
 	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
 		return cli.GetAllContacts(ctx)
 	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
-		where, args, err := cli.predicateBuilder.BuildPredicate(filter.Condition.GetNode().(*enginev1.PlanResourcesFilter_Expression_Operand_Expression))
+		where, args, residual, err := cli.buildPredicate(filter.Condition.GetNode().(*enginev1.PlanResourcesFilter_Expression_Operand_Expression))
 		if err != nil {
 			return nil, err
 		}
 		if where == "" {
-			return cli.GetAllContacts(ctx)
+			res, err = cli.GetAllContacts(ctx)
+		} else {
+			err = pgxscan.Select(ctx, cli.client, &res, "select * from contacts where "+where, args...)
 		}
-		err = pgxscan.Select(ctx, cli.client, &res, "select * from contacts where "+where, args...)
 		if err != nil {
 			return nil, err
 		}
-		return res, nil
+		if residual == nil {
+			return res, nil
+		}
+		return filterContacts(res, residual)
 	default:
 		return nil, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
 	}
 }
 
+// CountContacts returns the number of contacts matching the plan filter without fetching them,
+// unless the plan is only partially translated and the contacts have to be filtered in memory.
+func (cli *Client) CountContacts(ctx context.Context, filter *enginev1.PlanResourcesFilter) (n int64, err error) {
+	if filter.GetKind() == enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED {
+		return 0, nil
+	}
+	clause, args, partial, err := cli.filterClause(filter)
+	if err != nil {
+		return 0, err
+	}
+	if partial {
+		res, err := cli.GetContacts(ctx, filter)
+		return int64(len(res)), err
+	}
+	err = pgxscan.Get(ctx, cli.client, &n, "select count(*) from contacts"+clause, args...)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ExistsContacts tells whether any contact matches the plan filter without fetching the contacts,
+// unless the plan is only partially translated and the contacts have to be filtered in memory.
+func (cli *Client) ExistsContacts(ctx context.Context, filter *enginev1.PlanResourcesFilter) (ok bool, err error) {
+	if filter.GetKind() == enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED {
+		return false, nil
+	}
+	clause, args, partial, err := cli.filterClause(filter)
+	if err != nil {
+		return false, err
+	}
+	if partial {
+		res, err := cli.GetContacts(ctx, filter)
+		return len(res) > 0, err
+	}
+	err = pgxscan.Get(ctx, cli.client, &ok, "select exists (select 1 from contacts"+clause+")", args...)
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// filterClause returns the WHERE clause of the plan filter, or nothing if every contact matches.
+// partial is true if the plan is only partially translated, in which case the clause is not returned.
+func (cli *Client) filterClause(filter *enginev1.PlanResourcesFilter) (clause string, args []interface{}, partial bool, err error) {
+	if filter == nil {
+		return "", nil, false, errors.New("\"filter\" is nil")
+	}
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		return "", nil, false, nil
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		where, args, residual, err := cli.buildPredicate(filter.Condition.GetNode().(*enginev1.PlanResourcesFilter_Expression_Operand_Expression))
+		if err != nil || residual != nil {
+			return "", nil, residual != nil, err
+		}
+		if where == "" {
+			return "", nil, false, nil
+		}
+		return " where " + where, args, false, nil
+	default:
+		return "", nil, false, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+}
+
+// buildPredicate uses the partial translation of the predicate builder if it has one.
+func (cli *Client) buildPredicate(e *enginev1.PlanResourcesFilter_Expression_Operand_Expression) (where string, args []interface{}, residual func(any) (bool, error), err error) {
+	if b, ok := cli.predicateBuilder.(partialPredicateBuilder); ok {
+		return b.BuildPartialPredicate(e)
+	}
+	where, args, err = cli.predicateBuilder.BuildPredicate(e)
+	return where, args, nil, err
+}
+
+func filterContacts(contacts []*Contact, residual func(any) (bool, error)) ([]*Contact, error) {
+	var res []*Contact
+	for _, c := range contacts {
+		ok, err := residual(c)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
 func New(ctx context.Context, b predicateBuilder, url string) (*Client, error) {
 	c, err := pgx.Connect(ctx, url)
 	if err != nil {
@@ -70,6 +168,11 @@ func New(ctx context.Context, b predicateBuilder, url string) (*Client, error) {
 	return &Client{client: c, predicateBuilder: b}, nil
 }
 
+// Conn returns the connection of the client, e.g. to run the queries built by the adapter.
+func (cli *Client) Conn() *pgx.Conn {
+	return cli.client
+}
+
 func (cli *Client) GetUserByUsername(ctx context.Context, username string) (*User, error) {
 	user := new(User)
 	err := pgxscan.Get(ctx, cli.client, user, "select id, username, email, name, role, department from users where username=$1", username)
@@ -0,0 +1,239 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The differential test is shared with ent-adapter: both generate the same plans for a given seed
+// and compare the rows returned by the adapter with the contacts of db/seed.json matching the plan in memory.
+var (
+	diffSeed  = flag.Int64("diff.seed", 1, "seed of the plans generated by TestDifferential")
+	diffCount = flag.Int("diff.plans", 200, "number of plans generated by TestDifferential")
+)
+
+// diffAttr is a contact attribute of the generated plans along with the values it is compared with.
+// Strings are only compared for equality, because the ordering of strings depends on the collation of the database.
+type diffAttr struct {
+	name    string
+	values  []any
+	ordered bool
+}
+
+var diffAttrs = []diffAttr{
+	{name: "ownerId", values: []any{1.0, 2.0, 3.0, 4.0}, ordered: true},
+	{name: "active", values: []any{true, false}},
+	{name: "marketingOptIn", values: []any{true, false}},
+	{name: "firstName", values: []any{"Nick", "Mary", "Aleks", "Zoe"}},
+	{name: "lastName", values: []any{"Smyth", "Jane", "Baker", "Doe"}},
+}
+
+// seedContacts returns the attributes of the contacts of db/seed.json by first name.
+// The users and the contacts are inserted in order, so the ID of the owner of a contact is the position of the user.
+func seedContacts(t *testing.T) map[string]map[string]any {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("db", "seed.json"))
+	require.NoError(t, err)
+	var users []struct {
+		Contacts []struct {
+			FirstName      string `json:"firstName"`
+			LastName       string `json:"lastName"`
+			Active         bool   `json:"active"`
+			MarketingOptIn bool   `json:"marketingOptIn"`
+		} `json:"contacts"`
+	}
+	require.NoError(t, json.Unmarshal(b, &users))
+	contacts := make(map[string]map[string]any)
+	for i, u := range users {
+		for _, c := range u.Contacts {
+			contacts[c.FirstName] = map[string]any{
+				"ownerId":        float64(i + 1),
+				"active":         c.Active,
+				"marketingOptIn": c.MarketingOptIn,
+				"firstName":      c.FirstName,
+				"lastName":       c.LastName,
+			}
+		}
+	}
+	return contacts
+}
+
+// genPlan returns a random plan of logical operators over comparisons of diffAttrs with literal values.
+func genPlan(r *rand.Rand, depth int) *filterOp {
+	if depth > 1 && r.Intn(3) > 0 { //nolint:gomnd
+		switch r.Intn(3) { //nolint:gomnd
+		case 0:
+			return newExpression("not", genPlan(r, depth-1))
+		default:
+			operator := "and"
+			if r.Intn(2) == 0 {
+				operator = "or"
+			}
+			operands := make([]*filterOp, 2+r.Intn(2)) //nolint:gomnd
+			for i := range operands {
+				operands[i] = genPlan(r, depth-1)
+			}
+			return newExpression(operator, operands...)
+		}
+	}
+	a := diffAttrs[r.Intn(len(diffAttrs))]
+	variable := &filterOp{Node: &filterOpVariable{Variable: "request.resource.attr." + a.name}}
+	operators := []string{"eq", "ne", "in"}
+	if a.ordered {
+		operators = append(operators, "lt", "lte", "gt", "gte")
+	}
+	operator := operators[r.Intn(len(operators))]
+	var value *structpb.Value
+	if operator == "in" {
+		list := make([]any, 1+r.Intn(3)) //nolint:gomnd
+		for i := range list {
+			list[i] = a.values[r.Intn(len(a.values))]
+		}
+		value, _ = structpb.NewValue(list)
+	} else {
+		value, _ = structpb.NewValue(a.values[r.Intn(len(a.values))])
+	}
+	return newExpression(operator, variable, &filterOp{Node: &filterOpValue{Value: value}})
+}
+
+// minimise returns the smallest plan derived from o with reductions that still fails.
+func minimise(o *filterOp, fails func(*filterOp) bool) *filterOp {
+	for {
+		reduced := false
+		for _, c := range reductions(o) {
+			if fails(c) {
+				o, reduced = c, true
+				break
+			}
+		}
+		if !reduced {
+			return o
+		}
+	}
+}
+
+// reductions returns the plans obtained from o by replacing one of its logical expressions by one of its operands,
+// by removing one operand of an "and" or an "or" with more than two, or by removing one item of an "in" list.
+func reductions(o *filterOp) []*filterOp {
+	e, ok := o.GetNode().(*filterOpExpression)
+	if !ok {
+		return nil
+	}
+	operator, operands := e.Expression.Operator, e.Expression.Operands
+	var res []*filterOp
+	switch operator {
+	case "and", "or", "not":
+		res = append(res, operands...)
+		if len(operands) > 2 { //nolint:gomnd
+			for i := range operands {
+				rest := append(append([]*filterOp(nil), operands[:i]...), operands[i+1:]...)
+				res = append(res, newExpression(operator, rest...))
+			}
+		}
+		for i, operand := range operands {
+			for _, r := range reductions(operand) {
+				replaced := append([]*filterOp(nil), operands...)
+				replaced[i] = r
+				res = append(res, newExpression(operator, replaced...))
+			}
+		}
+	case "in":
+		values := operands[1].GetValue().GetListValue().GetValues()
+		if len(values) > 1 {
+			for i := range values {
+				rest := append(append([]*structpb.Value(nil), values[:i]...), values[i+1:]...)
+				list := structpb.NewListValue(&structpb.ListValue{Values: rest})
+				res = append(res, newExpression(operator, operands[0], &filterOp{Node: &filterOpValue{Value: list}}))
+			}
+		}
+	}
+	return res
+}
+
+// diffAdapter runs the generated plans through query and compares the names it returns with the names of
+// the contacts matching the plan according to match. A failing plan is reported along with its minimised form.
+func diffAdapter(t *testing.T, query func(*enginev1.PlanResourcesFilter) ([]string, error), match func(*filterOpExpression, map[string]any) (bool, error)) {
+	t.Helper()
+	contacts := seedContacts(t)
+	diff := func(o *filterOp) (got, want []string, err error) {
+		filter := &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL, Condition: o}
+		if got, err = query(filter); err != nil {
+			return nil, nil, fmt.Errorf("adapter: %w", err)
+		}
+		e, _ := o.GetNode().(*filterOpExpression)
+		for name, attrs := range contacts {
+			ok, err := match(e, attrs)
+			if err != nil {
+				return nil, nil, fmt.Errorf("evaluator: %w", err)
+			}
+			if ok {
+				want = append(want, name)
+			}
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		return got, want, nil
+	}
+	fails := func(o *filterOp) bool {
+		got, want, err := diff(o)
+		return err != nil || fmt.Sprint(got) != fmt.Sprint(want)
+	}
+
+	r := rand.New(rand.NewSource(*diffSeed)) //nolint:gosec
+	for i := 0; i < *diffCount; i++ {
+		plan := genPlan(r, 4) //nolint:gomnd
+		if !fails(plan) {
+			continue
+		}
+		minimised := minimise(proto.Clone(plan).(*filterOp), fails)
+		got, want, err := diff(minimised)
+		t.Errorf("plan %d of seed %d: adapter returned %v, evaluator matched %v, err: %v\nplan: %s\nminimised plan: %s",
+			i, *diffSeed, got, want, err, protojson.Format(plan), protojson.Format(minimised))
+	}
+}
+
+func TestDifferential(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires Postgres")
+	}
+	ctx := context.Background()
+	repo := startDatabase(ctx, t)
+	diffAdapter(t, func(filter *enginev1.PlanResourcesFilter) ([]string, error) {
+		contacts, err := repo.GetContacts(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		return getNames(contacts), nil
+	}, func(e *filterOpExpression, attrs map[string]any) (bool, error) {
+		p, err := Compile(e)
+		if err != nil {
+			return false, err
+		}
+		return p(columnAttrs(attrs))
+	})
+}
+
+// columnAttrs returns the attributes of a contact by column name, the way the evaluator looks them up.
+func columnAttrs(attrs map[string]any) map[string]any {
+	columns := make(map[string]any, len(attrs))
+	for name, v := range attrs {
+		columns[getFieldName(name)] = v
+	}
+	return columns
+}
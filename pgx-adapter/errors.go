@@ -0,0 +1,137 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCategory tells why a plan could not be translated, e.g. to decide whether to fall back to filtering in memory.
+type ErrorCategory int
+
+const (
+	// CategoryMalformed is a plan that is not a valid query plan, e.g. a "not" with two operands.
+	CategoryMalformed ErrorCategory = iota + 1
+	// CategoryUnsupported is a valid plan using an operator or a value that the adapter cannot translate.
+	CategoryUnsupported
+	// CategoryUnmappedAttribute is a variable that does not map to a column, e.g. a column that is not allowed
+	// or an unresolved principal attribute or binding.
+	CategoryUnmappedAttribute
+	// CategoryLimitExceeded is a plan exceeding one of the limits set with WithMaxDepth and the like.
+	CategoryLimitExceeded
+)
+
+var (
+	// ErrMalformedPlan is wrapped by the TranslationError of the CategoryMalformed category.
+	ErrMalformedPlan = errors.New("malformed query plan")
+	// ErrUnsupported is wrapped by the TranslationError of the CategoryUnsupported category.
+	ErrUnsupported = errors.New("unsupported query plan")
+	// ErrUnmappedAttribute is wrapped by the TranslationError of the CategoryUnmappedAttribute category.
+	ErrUnmappedAttribute = errors.New("unmapped attribute")
+)
+
+func (c ErrorCategory) String() string {
+	switch c {
+	case CategoryMalformed:
+		return "malformed"
+	case CategoryUnsupported:
+		return "unsupported"
+	case CategoryUnmappedAttribute:
+		return "unmapped attribute"
+	case CategoryLimitExceeded:
+		return "limit exceeded"
+	}
+	return fmt.Sprintf("ErrorCategory(%d)", int(c))
+}
+
+func (c ErrorCategory) sentinel() error {
+	switch c {
+	case CategoryMalformed:
+		return ErrMalformedPlan
+	case CategoryUnsupported:
+		return ErrUnsupported
+	case CategoryUnmappedAttribute:
+		return ErrUnmappedAttribute
+	case CategoryLimitExceeded:
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// TranslationError is returned when a plan cannot be translated. Use errors.As to get the details, or errors.Is
+// with the sentinel of a category, e.g. errors.Is(err, ErrUnsupported). The underlying error, such as
+// ErrExpressionExpected or a LimitError, is wrapped as well.
+type TranslationError struct {
+	Category ErrorCategory
+	// Operator is the operator of the offending expression, or of the expression the offending operand belongs to.
+	Operator string
+	// Path is the path of the offending node in the JSON form of the plan condition,
+	// e.g. "expression.operands[1].expression.operands[0].variable".
+	// With WithCanonicalShape, the operands are numbered in their canonical order.
+	Path string
+	Err  error
+}
+
+func (e *TranslationError) Error() string {
+	return fmt.Sprintf("%s: %s (operator %q): %v", e.Category, e.Path, e.Operator, e.Err)
+}
+
+func (e *TranslationError) Unwrap() []error {
+	if s := e.Category.sentinel(); s != nil {
+		return []error{s, e.Err}
+	}
+	return []error{e.Err}
+}
+
+// expressionError returns a TranslationError about the expression e.
+func expressionError(category ErrorCategory, e *filterOpExpression, err error) error {
+	return &TranslationError{Category: category, Operator: e.Expression.Operator, Path: "expression", Err: err}
+}
+
+// operandError returns a TranslationError about an operand that is not an expression, of the given kind
+// ("variable", "value" or "" for an operand without a node). inOperand completes its path and operator.
+func operandError(category ErrorCategory, kind string, err error) error {
+	return &TranslationError{Category: category, Path: kind, Err: err}
+}
+
+// operandKind returns the name of the field holding the node of an operand in its JSON form.
+func operandKind(operand *filterOp) string {
+	switch operand.GetNode().(type) {
+	case *filterOpVariable:
+		return "variable"
+	case *filterOpValue:
+		return "value"
+	}
+	return ""
+}
+
+// inOperand prefixes the path of a TranslationError with the i-th operand of an expression with the given operator,
+// which becomes the operator of the error if it has none yet. Other errors are returned as they are.
+func inOperand(err error, operator string, i int) error {
+	var te *TranslationError
+	if !errors.As(err, &te) {
+		return err
+	}
+	path := fmt.Sprintf("expression.operands[%d]", i)
+	if te.Path != "" {
+		path += "." + te.Path
+	}
+	te.Path = path
+	if te.Operator == "" {
+		te.Operator = operator
+	}
+	return err
+}
+
+// categoryOf returns the category of an error returned while resolving a variable.
+func categoryOf(err error) ErrorCategory {
+	switch {
+	case errors.Is(err, ErrLimitExceeded):
+		return CategoryLimitExceeded
+	case errors.Is(err, ErrUnresolvedBinding), errors.Is(err, ErrUnresolvedPrincipalAttribute), errors.Is(err, ErrColumnNotAllowed):
+		return CategoryUnmappedAttribute
+	}
+	return CategoryUnsupported
+}
@@ -0,0 +1,549 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/iancoleman/strcase"
+)
+
+// Predicate reports whether a resource, given as a map from column names to values, satisfies a query plan.
+// Column names are derived from the plan variables the same way the SQL predicate is built, with getFieldName
+// or the mapping of WithFieldNames, but without the table alias since a row is keyed by column name.
+type Predicate func(attrs map[string]any) (bool, error)
+
+// evalFunc evaluates a plan node. A nil result stands for SQL NULL, so the evaluator follows the same
+// three-valued logic as the database: comparisons with NULL yield NULL and only rows evaluating to true match.
+// As in the SQL, a comparison of a column with the null literal is IS [NOT] NULL, which is never NULL.
+type evalFunc func(attrs map[string]any) (any, error)
+
+var ErrIncomparable = errors.New("incomparable values")
+
+// evalOnlyOps are the operators that the evaluator supports but the SQL builder does not,
+// so that BuildPartialPredicate can evaluate them on the rows returned by the query.
+var evalOnlyOps = map[string]struct{}{
+	"startsWith":      {},
+	"endsWith":        {},
+	"contains":        {},
+	"hasIntersection": {},
+}
+
+// CompileFilter compiles a query plan filter into a Predicate. The options are those of Compile.
+func CompileFilter(filter *enginev1.PlanResourcesFilter, opts ...Option) (Predicate, error) {
+	if filter == nil {
+		return nil, errors.New("\"filter\" is nil")
+	}
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		return func(map[string]any) (bool, error) { return false, nil }, nil
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		return func(map[string]any) (bool, error) { return true, nil }, nil
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		e, ok := filter.Condition.GetNode().(*filterOpExpression)
+		if !ok {
+			return nil, ErrExpressionExpected
+		}
+		return Compile(e, opts...)
+	default:
+		return nil, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+}
+
+// Compile compiles a query plan expression into a Predicate.
+// Of the options, WithFieldNames and WithAllowedColumns apply.
+func Compile(e *filterOpExpression, opts ...Option) (Predicate, error) {
+	return compile(e, newOptions(opts...))
+}
+
+func compile(e *filterOpExpression, o *options) (Predicate, error) {
+	if e == nil {
+		return func(map[string]any) (bool, error) { return true, nil }, nil
+	}
+	f, err := compileImpl(e, o)
+	if err != nil {
+		return nil, err
+	}
+	return func(attrs map[string]any) (bool, error) {
+		v, err := f(attrs)
+		if err != nil {
+			return false, err
+		}
+		b, ok := v.(bool)
+		return ok && b, nil
+	}, nil
+}
+
+// EvalStruct evaluates the predicate against a struct such as db.Contact or a pointer to one.
+func (p Predicate) EvalStruct(v any) (bool, error) {
+	attrs, err := StructAttrs(v)
+	if err != nil {
+		return false, err
+	}
+	return p(attrs)
+}
+
+// FilterStructs returns the items satisfying the predicate.
+func FilterStructs[T any](p Predicate, items []T) ([]T, error) {
+	var res []T
+	for _, item := range items {
+		ok, err := p.EvalStruct(item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, item)
+		}
+	}
+	return res, nil
+}
+
+// StructAttrs converts the exported fields of a struct into a map keyed by column name.
+// The column name is taken from the "db" tag if present, otherwise it is the snake-cased field name,
+// matching the columns scanned into db.Contact.
+func StructAttrs(v any) (map[string]any, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, errors.New("nil struct pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %s", rv.Kind())
+	}
+	rt := rv.Type()
+	attrs := make(map[string]any, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strcase.ToSnake(f.Name)
+		if tag, ok := f.Tag.Lookup("db"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				attrs[name] = nil
+				continue
+			}
+			fv = fv.Elem()
+		}
+		attrs[name] = fv.Interface()
+	}
+	return attrs, nil
+}
+
+func compileImpl(e *filterOpExpression, o *options) (evalFunc, error) {
+	switch e.Expression.Operator {
+	case "or", "and":
+		fs := make([]evalFunc, len(e.Expression.Operands))
+		for i, operand := range e.Expression.Operands {
+			oe, ok := operand.GetNode().(*filterOpExpression)
+			if !ok {
+				return nil, ErrExpressionExpected
+			}
+			f, err := compileImpl(oe, o)
+			if err != nil {
+				return nil, err
+			}
+			fs[i] = f
+		}
+		if e.Expression.Operator == "or" {
+			return evalLogical(fs, true), nil
+		}
+		return evalLogical(fs, false), nil
+	case "not":
+		if len(e.Expression.Operands) != 1 {
+			return nil, fmt.Errorf("expected a unary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+		}
+		oe, ok := e.Expression.Operands[0].GetNode().(*filterOpExpression)
+		if !ok {
+			return nil, ErrExpressionExpected
+		}
+		f, err := compileImpl(oe, o)
+		if err != nil {
+			return nil, err
+		}
+		return func(attrs map[string]any) (any, error) {
+			v, err := f(attrs)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("operator \"not\": expected a boolean, got %T", v)
+			}
+			return !b, nil
+		}, nil
+	default:
+		if len(e.Expression.Operands) != 2 { //nolint:gomnd
+			return nil, fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", e.Expression.Operator, len(e.Expression.Operands))
+		}
+		op := e.Expression.Operator
+		_, isSQLOp := toSQLOp[op]
+		if _, ok := evalOnlyOps[op]; !ok && !isSQLOp {
+			return nil, fmt.Errorf("unsupported operation %q", op)
+		}
+		if i, ok := nullComparison(op, e.Expression.Operands); ok {
+			// The SQL builder writes IS [NOT] NULL, which is never NULL.
+			name, err := o.rowKey(e.Expression.Operands[i].GetVariable())
+			if err != nil {
+				return nil, err
+			}
+			isNull := op == "eq"
+			return func(attrs map[string]any) (any, error) {
+				return (attrs[name] == nil) == isNull, nil
+			}, nil
+		}
+		var args [2]evalFunc
+		for i, operand := range e.Expression.Operands {
+			f, err := compileOperand(operand, o)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = f
+		}
+		return func(attrs map[string]any) (any, error) {
+			l, err := args[0](attrs)
+			if err != nil {
+				return nil, err
+			}
+			r, err := args[1](attrs)
+			if err != nil {
+				return nil, err
+			}
+			return evalBinary(op, l, r)
+		}, nil
+	}
+}
+
+func compileOperand(operand *filterOp, o *options) (evalFunc, error) {
+	switch eo := operand.Node.(type) {
+	case *filterOpExpression:
+		return compileImpl(eo, o)
+	case *filterOpVariable:
+		name, err := o.rowKey(eo.Variable)
+		if err != nil {
+			return nil, err
+		}
+		return func(attrs map[string]any) (any, error) {
+			return normalize(attrs[name]), nil
+		}, nil
+	case *filterOpValue:
+		v := literal(eo.Value.AsInterface())
+		return func(map[string]any) (any, error) {
+			return v, nil
+		}, nil
+	}
+	return nil, errors.New("unknown Node type")
+}
+
+// rowKey returns the key of a plan variable in the attributes of a Predicate, the column that the SQL reads.
+// A column rejected by WithAllowedColumns fails the same way as in the SQL.
+func (o *options) rowKey(variable string) (string, error) {
+	if o.allowedColumns != nil {
+		if _, err := o.column(variable); err != nil {
+			return "", err
+		}
+	}
+	return o.fieldName(variable), nil
+}
+
+func evalLogical(fs []evalFunc, isOr bool) evalFunc {
+	return func(attrs map[string]any) (any, error) {
+		sawNull := false
+		for _, f := range fs {
+			v, err := f(attrs)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				sawNull = true
+				continue
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("expected a boolean, got %T", v)
+			}
+			if b == isOr {
+				return b, nil
+			}
+		}
+		if sawNull {
+			return nil, nil
+		}
+		return !isOr, nil
+	}
+}
+
+func evalBinary(op string, l, r any) (any, error) {
+	if op == "in" {
+		return evalIn(l, r)
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	switch op {
+	case "eq", "ne":
+		c, err := compare(l, r)
+		if err != nil {
+			return nil, fmt.Errorf("operator %q: %w", op, err)
+		}
+		return (c == 0) == (op == "eq"), nil
+	case "lt", "lte", "gt", "gte":
+		c, err := compare(l, r)
+		if err != nil {
+			return nil, fmt.Errorf("operator %q: %w", op, err)
+		}
+		switch op {
+		case "lt":
+			return c < 0, nil
+		case "lte":
+			return c <= 0, nil
+		case "gt":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "startsWith", "endsWith", "contains":
+		return evalString(op, l, r)
+	case "hasIntersection":
+		return evalHasIntersection(l, r)
+	default:
+		return evalArithmetic(op, l, r)
+	}
+}
+
+func evalIn(l, r any) (any, error) {
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(r)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("operator \"in\": expected a list, got %T", r)
+	}
+	sawNull := false
+	for i := 0; i < rv.Len(); i++ {
+		item := normalize(rv.Index(i).Interface())
+		if item == nil {
+			sawNull = true
+			continue
+		}
+		c, err := compare(l, item)
+		if err != nil {
+			return nil, fmt.Errorf("operator \"in\": %w", err)
+		}
+		if c == 0 {
+			return true, nil
+		}
+	}
+	if sawNull {
+		return nil, nil
+	}
+	return false, nil
+}
+
+func evalString(op string, l, r any) (any, error) {
+	x, ok1 := stringValue(l)
+	y, ok2 := stringValue(r)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("operator %q: expected strings, got %T and %T", op, l, r)
+	}
+	switch op {
+	case "startsWith":
+		return strings.HasPrefix(x, y), nil
+	case "endsWith":
+		return strings.HasSuffix(x, y), nil
+	default:
+		return strings.Contains(x, y), nil
+	}
+}
+
+// evalHasIntersection reports whether two lists have an item in common. NULL items match nothing.
+func evalHasIntersection(l, r any) (any, error) {
+	lv, rv := reflect.ValueOf(l), reflect.ValueOf(r)
+	for _, v := range []reflect.Value{lv, rv} {
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("operator \"hasIntersection\": expected a list, got %s", v.Type())
+		}
+	}
+	for i := 0; i < lv.Len(); i++ {
+		x := normalize(lv.Index(i).Interface())
+		if x == nil {
+			continue
+		}
+		for j := 0; j < rv.Len(); j++ {
+			y := normalize(rv.Index(j).Interface())
+			if y == nil {
+				continue
+			}
+			c, err := compare(x, y)
+			if err != nil {
+				return nil, fmt.Errorf("operator \"hasIntersection\": %w", err)
+			}
+			if c == 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func evalArithmetic(op string, l, r any) (any, error) {
+	x, ok1 := l.(float64)
+	y, ok2 := r.(float64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("operator %q: %w: %T and %T", op, ErrIncomparable, l, r)
+	}
+	switch op {
+	case "add":
+		return x + y, nil
+	case "sub":
+		return x - y, nil
+	case "mult":
+		return x * y, nil
+	case "div", "mod":
+		if y == 0 {
+			return nil, fmt.Errorf("operator %q: division by zero", op)
+		}
+		if op == "div" {
+			return x / y, nil
+		}
+		return math.Mod(x, y), nil
+	}
+	return nil, fmt.Errorf("unsupported operation %q", op)
+}
+
+// untyped is a string value of the plan. Like the text parameters that pgx sends for strings, which Postgres
+// casts to the type of the column, it compares with a number or a timestamp by parsing it, e.g. owner_id = '2'.
+// Other values of different types are incomparable, e.g. a string column and the number 2.
+type untyped string
+
+// literal converts a value of the plan for the evaluator, see untyped and normalize.
+func literal(v any) any {
+	switch x := v.(type) {
+	case string:
+		return untyped(x)
+	case []any:
+		items := make([]any, len(x))
+		for i, item := range x {
+			items[i] = literal(item)
+		}
+		return items
+	}
+	return normalize(v)
+}
+
+func stringValue(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case untyped:
+		return string(x), true
+	}
+	return "", false
+}
+
+// compare returns -1, 0 or +1 depending on whether l is less than, equal to or greater than r.
+func compare(l, r any) (int, error) {
+	if y, ok := r.(untyped); ok {
+		if _, ok := l.(untyped); !ok {
+			c, err := compare(y, l)
+			return -c, err
+		}
+	}
+	switch x := l.(type) {
+	case float64:
+		if y, ok := r.(float64); ok {
+			return compareFloat(x, y), nil
+		}
+	case string:
+		if y, ok := r.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case untyped:
+		switch y := r.(type) {
+		case string, untyped:
+			s, _ := stringValue(y)
+			return strings.Compare(string(x), s), nil
+		case float64:
+			if f, err := strconv.ParseFloat(string(x), 64); err == nil {
+				return compareFloat(f, y), nil
+			}
+		case time.Time:
+			if t, err := time.Parse(time.RFC3339Nano, string(x)); err == nil {
+				return t.Compare(y), nil
+			}
+		}
+	case bool:
+		if y, ok := r.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !x:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	case time.Time:
+		if y, ok := r.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %T and %T", ErrIncomparable, l, r)
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// normalize converts numeric values to float64, the representation of numbers in a query plan.
+func normalize(v any) any {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int8:
+		return float64(x)
+	case int16:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case uint8:
+		return float64(x)
+	case uint16:
+		return float64(x)
+	case uint32:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	}
+	return v
+}
@@ -0,0 +1,179 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/cerbos/cerbos-go-adapters/pgx-adapter/db"
+)
+
+func mustExpression(t *testing.T, input string) *filterOpExpression {
+	t.Helper()
+
+	e := new(enginev1.PlanResourcesFilter_Expression_Operand)
+	require.NoError(t, protojson.Unmarshal([]byte(input), e))
+	oe, ok := e.Node.(*filterOpExpression)
+	require.True(t, ok, "expected an expression: %s", input)
+	return oe
+}
+
+func Test_Compile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		attrs map[string]any
+		want  bool
+		err   error
+	}{
+		{
+			name:  "eq string to int column",
+			input: `{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.ownerId"},{"value":"2"}]}}`,
+			attrs: map[string]any{"owner_id": 2},
+			want:  true,
+		},
+		{
+			name:  "eq number to string column",
+			input: `{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.lastName"},{"value":2}]}}`,
+			attrs: map[string]any{"last_name": "2"},
+			err:   ErrIncomparable,
+		},
+		{
+			name:  "eq string to string column",
+			input: `{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.lastName"},{"value":"2.0"}]}}`,
+			attrs: map[string]any{"last_name": "2"},
+			want:  false,
+		},
+		{
+			name:  "eq null",
+			input: `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.companyId"},{"value":null}]}}`,
+			attrs: map[string]any{"company_id": nil},
+			want:  true,
+		},
+		{
+			name:  "ne null",
+			input: `{"expression":{"operator":"not","operands":[{"expression":{"operator":"ne","operands":[{"value":null},{"variable":"R.attr.companyId"}]}}]}}`,
+			attrs: map[string]any{},
+			want:  true,
+		},
+		{
+			name:  "arithmetic",
+			input: `{"expression":{"operator":"lt","operands":[{"expression":{"operator":"add","operands":[{"variable":"a"},{"variable":"b"}]}},{"value":10}]}}`,
+			attrs: map[string]any{"a": 3, "b": int64(6)},
+			want:  true,
+		},
+		{
+			name:  "in",
+			input: `{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","Marketing"]}]}}`,
+			attrs: map[string]any{"department": "Marketing"},
+			want:  true,
+		},
+		{
+			name:  "null comparison is not true",
+			input: `{"expression":{"operator":"ne","operands":[{"variable":"R.attr.companyId"},{"value":1}]}}`,
+			attrs: map[string]any{"company_id": nil},
+			want:  false,
+		},
+		{
+			name:  "not of null is not true",
+			input: `{"expression":{"operator":"not","operands":[{"expression":{"operator":"eq","operands":[{"variable":"R.attr.companyId"},{"value":1}]}}]}}`,
+			attrs: map[string]any{},
+			want:  false,
+		},
+		{
+			name:  "or with null",
+			input: `{"expression":{"operator":"or","operands":[{"expression":{"operator":"eq","operands":[{"variable":"R.attr.companyId"},{"value":1}]}},{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}}]}}`,
+			attrs: map[string]any{"active": true},
+			want:  true,
+		},
+		{
+			name:  "startsWith",
+			input: `{"expression":{"operator":"startsWith","operands":[{"variable":"R.attr.firstName"},{"value":"Ni"}]}}`,
+			attrs: map[string]any{"first_name": "Nick"},
+			want:  true,
+		},
+		{
+			name:  "hasIntersection",
+			input: `{"expression":{"operator":"hasIntersection","operands":[{"variable":"R.attr.tags"},{"value":["x","y"]}]}}`,
+			attrs: map[string]any{"tags": []string{"y", "z"}},
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			p, err := Compile(mustExpression(t, tt.input))
+			is.NoError(err)
+			got, err := p(tt.attrs)
+			if tt.err != nil {
+				is.ErrorIs(err, tt.err)
+				return
+			}
+			is.NoError(err)
+			is.Equal(tt.want, got)
+		})
+	}
+}
+
+func Test_CompileUnsupported(t *testing.T) {
+	is := require.New(t)
+	_, err := Compile(mustExpression(t, `{"expression":{"operator":"matches","operands":[{"variable":"a"},{"value":"x"}]}}`))
+	is.Error(err)
+	_, err = Compile(mustExpression(t, `{"expression":{"operator":"not","operands":[]}}`))
+	is.Error(err)
+}
+
+func Test_CompileFieldNames(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.ownerId"},{"value":"2"}]}}`)
+	p, err := Compile(e, WithFieldNames(map[string]string{"ownerId": "owner"}), WithTableAlias("c"))
+	is.NoError(err)
+	ok, err := p(map[string]any{"owner": 2, "owner_id": 3})
+	is.NoError(err)
+	is.True(ok)
+
+	_, err = Compile(e, WithAllowedColumns("owner_id"))
+	is.NoError(err)
+	_, err = Compile(e, WithFieldNames(map[string]string{"ownerId": "owner"}), WithAllowedColumns("owner_id"))
+	is.ErrorIs(err, ErrColumnNotAllowed)
+}
+
+func Test_FilterStructs(t *testing.T) {
+	is := require.New(t)
+	// The plan Cerbos returns for a "user" in the Sales department with ID 2.
+	filter := &enginev1.PlanResourcesFilter{
+		Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: &enginev1.PlanResourcesFilter_Expression_Operand{
+			Node: mustExpression(t, `{"expression":{"operator":"or","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.ownerId"},{"value":"2"}]}},
+				{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.active"},{"value":true}]}}]}}`),
+		},
+	}
+	p, err := CompileFilter(filter)
+	is.NoError(err)
+	contacts := []*db.Contact{
+		{FirstName: "Nick", OwnerID: 2, Active: true},
+		{FirstName: "Simon", OwnerID: 2},
+		{FirstName: "Christina", OwnerID: 3},
+		{FirstName: "Aleks", OwnerID: 3, Active: true},
+	}
+	got, err := FilterStructs(p, contacts)
+	is.NoError(err)
+	is.ElementsMatch([]string{"Nick", "Simon", "Aleks"}, getNames(got))
+
+	for kind, want := range map[enginev1.PlanResourcesFilter_Kind]bool{
+		enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED: true,
+		enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:  false,
+	} {
+		p, err := CompileFilter(&enginev1.PlanResourcesFilter{Kind: kind})
+		is.NoError(err)
+		ok, err := p.EvalStruct(contacts[0])
+		is.NoError(err)
+		is.Equal(want, ok, kind)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	responsev1 "github.com/cerbos/cerbos/api/genpb/cerbos/response/v1"
+)
+
+// Explanation is the result of Explain: the SQL and its arguments, along with a tree mapping each fragment
+// of the SQL back to the plan node it was built from.
+type Explanation struct {
+	SQL  string        `json:"sql"`
+	Args []interface{} `json:"args,omitempty"`
+	// FilterDebug is the plan as printed by Cerbos in the filterDebug field of the response, if known.
+	FilterDebug string `json:"filterDebug,omitempty"`
+	// ValidationErrors are the schema validation errors reported by Cerbos along with the plan.
+	ValidationErrors []string     `json:"validationErrors,omitempty"`
+	Root             *ExplainNode `json:"root,omitempty"`
+}
+
+// ExplainNode is a plan expression and the SQL fragment it was translated to.
+// Expression uses the notation of filterDebug, e.g. (eq request.resource.attr.ownerId "2"), so a node can be
+// found in the plan printed by Cerbos. Nodes rewritten by the builder, such as the "in" merging the equalities
+// of an "or", or the variables substituted by WithPrincipalAttrs, have the expression they were rewritten to.
+type ExplainNode struct {
+	SQL        string         `json:"sql"`
+	Operator   string         `json:"operator"`
+	Expression string         `json:"expression"`
+	Children   []*ExplainNode `json:"children,omitempty"`
+	start      int
+}
+
+// explainer records the tree of ExplainNode while buildPredicateImpl writes the SQL.
+type explainer struct {
+	root  *ExplainNode
+	stack []*ExplainNode
+}
+
+// enter starts the node of e, whose SQL starts at offset start of the builder.
+func (x *explainer) enter(e *filterOpExpression, start int) {
+	n := &ExplainNode{Operator: e.Expression.Operator, Expression: expressionString(e), start: start}
+	if len(x.stack) == 0 {
+		x.root = n
+	} else {
+		parent := x.stack[len(x.stack)-1]
+		parent.Children = append(parent.Children, n)
+	}
+	x.stack = append(x.stack, n)
+}
+
+// leave ends the node on top of the stack, whose SQL ends at the current end of the builder.
+func (x *explainer) leave(b *strings.Builder) {
+	n := x.stack[len(x.stack)-1]
+	n.SQL = b.String()[n.start:]
+	x.stack = x.stack[:len(x.stack)-1]
+}
+
+// Explain translates e like NewPredicateBuilder(opts...) and explains where each fragment of the SQL comes from.
+func Explain(e *filterOpExpression, opts ...Option) (*Explanation, error) {
+	return explain(e, newOptions(opts...))
+}
+
+// ExplainPlan explains the translation of the filter of a plan response, e.g. the PlanResourcesResponse
+// embedded in the response of the Cerbos Go SDK. The filterDebug and the validation errors of the response
+// are included in the explanation.
+func ExplainPlan(res *responsev1.PlanResourcesResponse, opts ...Option) (*Explanation, error) {
+	return explainPlan(res, newOptions(opts...))
+}
+
+func explainPlan(res *responsev1.PlanResourcesResponse, o *options) (*Explanation, error) {
+	filter := res.GetFilter()
+	if filter == nil {
+		return nil, errors.New("\"filter\" is nil")
+	}
+	var x *Explanation
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		x = &Explanation{SQL: "TRUE", Root: &ExplainNode{SQL: "TRUE", Operator: "true", Expression: "true"}}
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		x = &Explanation{SQL: "FALSE", Root: &ExplainNode{SQL: "FALSE", Operator: "false", Expression: "false"}}
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		e, ok := filter.Condition.GetNode().(*filterOpExpression)
+		if !ok {
+			return nil, ErrExpressionExpected
+		}
+		var err error
+		if x, err = explain(e, o); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+	x.FilterDebug = res.GetMeta().GetFilterDebug()
+	for _, ve := range res.GetValidationErrors() {
+		x.ValidationErrors = append(x.ValidationErrors, fmt.Sprintf("%s %s: %s", ve.GetSource(), ve.GetPath(), ve.GetMessage()))
+	}
+	return x, nil
+}
+
+func explain(e *filterOpExpression, o *options) (*Explanation, error) {
+	o.explainer = new(explainer)
+	where, args, err := buildPredicate(e, o)
+	if err != nil {
+		return nil, err
+	}
+	x := &Explanation{SQL: where, Args: args, Root: o.explainer.root}
+	if x.Root != nil {
+		x.Root.SQL = where
+	}
+	return x, nil
+}
+
+// JSON returns the explanation as indented JSON.
+func (x *Explanation) JSON() ([]byte, error) {
+	return json.MarshalIndent(x, "", "  ")
+}
+
+// String returns the explanation as text: the SQL and its arguments, the plan printed by Cerbos,
+// then the tree with the SQL of each node followed by its plan expression.
+func (x *Explanation) String() string {
+	b := new(strings.Builder)
+	b.WriteString(x.SQL)
+	b.WriteRune('\n')
+	for i, a := range x.Args {
+		j, err := json.Marshal(a)
+		if err != nil {
+			j = []byte(fmt.Sprint(a))
+		}
+		fmt.Fprintf(b, "-- $%d = %s\n", i+1, j)
+	}
+	if x.FilterDebug != "" {
+		fmt.Fprintf(b, "-- filter: %s\n", x.FilterDebug)
+	}
+	for _, ve := range x.ValidationErrors {
+		fmt.Fprintf(b, "-- validation error: %s\n", ve)
+	}
+	if x.Root != nil {
+		b.WriteRune('\n')
+		x.Root.write(b, "")
+	}
+	return b.String()
+}
+
+func (n *ExplainNode) write(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%s%s\n%s  <- %s\n", indent, n.SQL, indent, n.Expression)
+	for _, c := range n.Children {
+		c.write(b, indent+"  ")
+	}
+}
+
+// expressionString renders a plan node in the notation of filterDebug, e.g. (eq request.resource.attr.ownerId "2").
+func expressionString(e *filterOpExpression) string {
+	b := new(strings.Builder)
+	writeOperand(&filterOp{Node: e}, b)
+	return b.String()
+}
+
+func writeOperand(o *filterOp, b *strings.Builder) {
+	switch n := o.GetNode().(type) {
+	case *filterOpExpression:
+		b.WriteRune('(')
+		b.WriteString(n.Expression.Operator)
+		for _, operand := range n.Expression.Operands {
+			b.WriteRune(' ')
+			writeOperand(operand, b)
+		}
+		b.WriteRune(')')
+	case *filterOpVariable:
+		b.WriteString(n.Variable)
+	case *filterOpValue:
+		j, err := json.Marshal(n.Value.AsInterface())
+		if err != nil {
+			j = []byte(n.Value.String())
+		}
+		b.Write(j)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	responsev1 "github.com/cerbos/cerbos/api/genpb/cerbos/response/v1"
+	schemav1 "github.com/cerbos/cerbos/api/genpb/cerbos/schema/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Explain(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"not","operands":[{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}]}},
+		{"expression":{"operator":"or","operands":[
+			{"expression":{"operator":"eq","operands":[{"variable":"R.attr.department"},{"value":"Sales"}]}},
+			{"expression":{"operator":"eq","operands":[{"variable":"R.attr.department"},{"value":"Marketing"}]}}]}}]}}`)
+
+	x, err := Explain(e)
+	is.NoError(err)
+	is.Equal(`(NOT ("owner_id" = $1)) AND (("department" = ANY($2)))`, x.SQL)
+	is.Equal([]interface{}{"2", []interface{}{"Sales", "Marketing"}}, x.Args)
+
+	root := x.Root
+	is.Equal(x.SQL, root.SQL)
+	is.Equal("and", root.Operator)
+	is.Len(root.Children, 2)
+
+	not := root.Children[0]
+	is.Equal(`(NOT ("owner_id" = $1))`, not.SQL)
+	is.Equal(`(not (eq R.attr.ownerId "2"))`, not.Expression)
+	is.Len(not.Children, 1)
+	is.Equal(`("owner_id" = $1)`, not.Children[0].SQL)
+	is.Equal(`(eq R.attr.ownerId "2")`, not.Children[0].Expression)
+
+	or := root.Children[1]
+	is.Equal(`(("department" = ANY($2)))`, or.SQL)
+	is.Len(or.Children, 1)
+	is.Equal("in", or.Children[0].Operator)
+	is.Equal(`(in R.attr.department ["Sales","Marketing"])`, or.Children[0].Expression)
+}
+
+func Test_ExplainPlan(t *testing.T) {
+	is := require.New(t)
+	res := &responsev1.PlanResourcesResponse{
+		Filter: &enginev1.PlanResourcesFilter{
+			Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+			Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`)},
+		},
+		Meta: &responsev1.PlanResourcesResponse_Meta{FilterDebug: `(eq request.resource.attr.ownerId "2")`},
+		ValidationErrors: []*schemav1.ValidationError{
+			{Path: "/ownerId", Message: "expected string", Source: schemav1.ValidationError_SOURCE_RESOURCE},
+		},
+	}
+
+	x, err := ExplainPlan(res)
+	is.NoError(err)
+	is.Equal(`"owner_id" = $1
+-- $1 = "2"
+-- filter: (eq request.resource.attr.ownerId "2")
+-- validation error: SOURCE_RESOURCE /ownerId: expected string
+
+"owner_id" = $1
+  <- (eq R.attr.ownerId "2")
+`, x.String())
+
+	b, err := x.JSON()
+	is.NoError(err)
+	var j map[string]any
+	is.NoError(json.Unmarshal(b, &j))
+	is.Equal(`(eq request.resource.attr.ownerId "2")`, j["filterDebug"])
+	is.Equal(`(eq R.attr.ownerId "2")`, j["root"].(map[string]any)["expression"])
+
+	res.Filter = &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED}
+	x, err = ExplainPlan(res)
+	is.NoError(err)
+	is.Equal("FALSE", x.SQL)
+}
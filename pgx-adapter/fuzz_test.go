@@ -0,0 +1,25 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/cerbos/cerbos-go-adapters/internal/fuzzplan"
+)
+
+func FuzzBuildPredicate(f *testing.F) {
+	fuzzplan.AddSeeds(f, yamlBytes)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		e := fuzzplan.Decode(b)
+		if e == nil {
+			return
+		}
+		where, args, err := BuildPredicate(e)
+		if err != nil {
+			return
+		}
+		fuzzplan.CheckSQL(t, where, len(args))
+	})
+}
@@ -3,6 +3,10 @@ module github.com/cerbos/cerbos-go-adapters/pgx-adapter
 go 1.25.0
 
 require (
+	github.com/Masterminds/squirrel v1.5.4
+	github.com/cerbos/cerbos-go-adapters/internal/cerbostest v0.0.0-00010101000000-000000000000
+	github.com/cerbos/cerbos-go-adapters/internal/fuzzplan v0.0.0-00010101000000-000000000000
+	github.com/cerbos/cerbos-go-adapters/internal/planfile v0.0.0-00010101000000-000000000000
 	github.com/cerbos/cerbos-sdk-go v0.3.13
 	github.com/cerbos/cerbos/api/genpb v0.52.0
 	github.com/fergusstrange/embedded-postgres v1.33.0
@@ -12,6 +16,7 @@ require (
 	github.com/jackc/pgx/v5 v5.9.2
 	github.com/ory/dockertest/v3 v3.12.0
 	github.com/stretchr/testify v1.11.1
+	google.golang.org/grpc v1.80.0
 	google.golang.org/protobuf v1.36.11
 )
 
@@ -59,6 +64,8 @@ require (
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/jdx/go-netrc v1.0.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.11 // indirect
+	github.com/lann/builder v0.0.0-20180802200727-47ae307949d0 // indirect
+	github.com/lann/ps v0.0.0-20150810152359-62de8c46ede0 // indirect
 	github.com/lestrrat-go/blackmagic v1.0.4 // indirect
 	github.com/lestrrat-go/dsig v1.0.0 // indirect
 	github.com/lestrrat-go/dsig-secp256k1 v1.0.0 // indirect
@@ -109,7 +116,12 @@ require (
 	golang.org/x/text v0.36.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20260420184626-e10c466a9529 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20260414002931-afd174a4e478 // indirect
-	google.golang.org/grpc v1.80.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/cerbos/cerbos-go-adapters/internal/cerbostest => ../internal/cerbostest
+
+replace github.com/cerbos/cerbos-go-adapters/internal/fuzzplan => ../internal/fuzzplan
+
+replace github.com/cerbos/cerbos-go-adapters/internal/planfile => ../internal/planfile
@@ -0,0 +1,94 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_QuoteIdentifier(t *testing.T) {
+	is := require.New(t)
+	is.Equal(`"owner_id"`, quoteIdentifier("owner_id"))
+	is.Equal(`"cerbforce"."contacts"."owner_id"`, quoteIdentifier("cerbforce.contacts.owner_id"))
+	is.Equal(`"a"" OR 1=1 --"`, quoteIdentifier(`a" OR 1=1 --`))
+	is.Equal(`"ab"`, quoteIdentifier("a\x00b"))
+}
+
+func Test_AllowedColumns(t *testing.T) {
+	is := require.New(t)
+	fieldNames := WithFieldNames(map[string]string{"ownerId": "cerbforce.contacts.owner_id"})
+
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}}]}}`)
+
+	where, _, err := NewPredicateBuilder(fieldNames, WithAllowedColumns("cerbforce.contacts.owner_id", "active")).BuildPredicate(e)
+	is.NoError(err)
+	is.Equal(`("cerbforce"."contacts"."owner_id" = $1) AND ("active" = $2)`, where)
+
+	_, _, err = NewPredicateBuilder(fieldNames, WithAllowedColumns("active")).BuildPredicate(e)
+	is.ErrorIs(err, ErrColumnNotAllowed)
+
+	_, err = BuildSqlizer(e, fieldNames, WithAllowedColumns("active"))
+	is.ErrorIs(err, ErrColumnNotAllowed)
+}
+
+func FuzzQuoteIdentifier(f *testing.F) {
+	for _, seed := range []string{"owner_id", "cerbforce.contacts.owner_id", `a"b`, `""`, "a\x00b", "..", `"."`} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		quoted := quoteIdentifier(name)
+		parts := strings.Split(name, ".")
+		// Every part is a single quoted identifier: it starts and ends with a quote and has no lone quote inside,
+		// so the parts can be recovered by unquoting.
+		var unquoted []string
+		rest := quoted
+		for i := range parts {
+			require.True(t, strings.HasPrefix(rest, `"`), "part %d of %q is not quoted: %s", i, name, quoted)
+			rest = rest[1:]
+			var b strings.Builder
+			for {
+				j := strings.IndexByte(rest, '"')
+				require.GreaterOrEqual(t, j, 0, "unterminated identifier: %s", quoted)
+				b.WriteString(rest[:j])
+				rest = rest[j+1:]
+				if !strings.HasPrefix(rest, `"`) {
+					break
+				}
+				b.WriteByte('"')
+				rest = rest[1:]
+			}
+			unquoted = append(unquoted, b.String())
+			if i < len(parts)-1 {
+				require.True(t, strings.HasPrefix(rest, "."), "missing separator: %s", quoted)
+				rest = rest[1:]
+			}
+		}
+		require.Empty(t, rest, "trailing characters: %s", quoted)
+		for i, p := range parts {
+			require.Equal(t, strings.ReplaceAll(p, "\x00", ""), unquoted[i])
+		}
+	})
+}
+
+func Test_TableAlias(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"lt","operands":[{"expression":{"operator":"add","operands":[{"variable":"a"},{"variable":"b"}]}},{"value":10}]}}]}}`)
+
+	where, _, err := NewPredicateBuilder(WithTableAlias("c")).BuildPredicate(e)
+	is.NoError(err)
+	is.Equal(`("c"."owner_id" = $1) AND (("c"."a" + "c"."b") < $2)`, where)
+
+	s, err := BuildSqlizer(e, WithTableAlias("c"))
+	is.NoError(err)
+	where, _, err = s.ToSql()
+	is.NoError(err)
+	is.Equal(`("c"."owner_id" = ? AND ("c"."a" + "c"."b") < ?)`, where)
+}
@@ -0,0 +1,125 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxPostgresParameters is the number of parameters a Postgres statement can have, and the default for WithMaxArgs.
+const MaxPostgresParameters = 65535
+
+// DefaultMaxDepth is the default for WithMaxDepth. Cerbos writes "and" and "or" with any number of operands,
+// so real plans are far shallower, while the passes over the plan recurse once per level.
+const DefaultMaxDepth = 64
+
+// ErrLimitExceeded is wrapped by the LimitError returned when a plan exceeds one of the configured limits.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError reports which limit a plan exceeded. Use errors.Is(err, ErrLimitExceeded) to detect any of them.
+type LimitError struct {
+	// Limit is one of "depth", "nodes", "args" or "list length".
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("query plan exceeds the maximum %s of %d", e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// WithMaxDepth limits the nesting depth of the plan. The root expression is at depth 1. Zero means no limit.
+// Defaults to DefaultMaxDepth.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// WithMaxNodes limits the number of expressions, variables and values in the plan. Zero means no limit.
+func WithMaxNodes(n int) Option {
+	return func(o *options) {
+		o.maxNodes = n
+	}
+}
+
+// WithMaxArgs limits the number of bound arguments. Zero means no limit. Defaults to MaxPostgresParameters.
+func WithMaxArgs(n int) Option {
+	return func(o *options) {
+		o.maxArgs = n
+	}
+}
+
+// WithMaxListLength limits the number of items in a literal list. Zero means no limit.
+func WithMaxListLength(n int) Option {
+	return func(o *options) {
+		o.maxListLength = n
+	}
+}
+
+// WithListSpill makes BuildSqlizer bind the literal lists with more than n items as a single array parameter,
+// "col" = ANY(?), instead of one parameter per item, which requires Postgres and a driver that encodes []any
+// such as pgx. BuildPredicate always binds a list as a single array parameter.
+func WithListSpill(n int) Option {
+	return func(o *options) {
+		o.spillThreshold = n
+	}
+}
+
+func (o *options) spill(n int) bool {
+	return o.spillThreshold > 0 && n > o.spillThreshold
+}
+
+// limiter enforces the limits of the options while a plan is translated.
+type limiter struct {
+	*options
+	nodes int
+}
+
+// checkPlan checks the depth and the number of nodes of the plan as it is given, before the passes that
+// rewrite it, such as resolve, canonicalize and mergeEqualities, which recurse over it or shrink it.
+func (l *limiter) checkPlan(e *filterOpExpression, depth int) error {
+	if err := l.visit(depth); err != nil {
+		return expressionError(CategoryLimitExceeded, e, err)
+	}
+	for i, operand := range e.Expression.Operands {
+		var err error
+		if oe, ok := operand.GetNode().(*filterOpExpression); ok {
+			err = l.checkPlan(oe, depth+1)
+		} else if err = l.visit(depth + 1); err != nil {
+			err = operandError(CategoryLimitExceeded, operandKind(operand), err)
+		}
+		if err != nil {
+			return inOperand(err, e.Expression.Operator, i)
+		}
+	}
+	return nil
+}
+
+// visit accounts for a plan node at the given depth.
+func (l *limiter) visit(depth int) error {
+	if l.maxDepth > 0 && depth > l.maxDepth {
+		return &LimitError{Limit: "depth", Max: l.maxDepth}
+	}
+	l.nodes++
+	if l.maxNodes > 0 && l.nodes > l.maxNodes {
+		return &LimitError{Limit: "nodes", Max: l.maxNodes}
+	}
+	return nil
+}
+
+// bind checks the number of bound arguments after one has been added, and the length of the argument if it is a list.
+func (l *limiter) bind(nargs int, arg interface{}) error {
+	if l.maxArgs > 0 && nargs > l.maxArgs {
+		return &LimitError{Limit: "args", Max: l.maxArgs}
+	}
+	if list, ok := arg.([]interface{}); ok && l.maxListLength > 0 && len(list) > l.maxListLength {
+		return &LimitError{Limit: "list length", Max: l.maxListLength}
+	}
+	return nil
+}
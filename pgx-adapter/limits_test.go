@@ -0,0 +1,68 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Limits(t *testing.T) {
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"not","operands":[
+			{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","IT","Marketing"]}]}}]}}]}}`)
+	tests := []struct {
+		name  string
+		opts  []Option
+		limit string
+	}{
+		{name: "depth", opts: []Option{WithMaxDepth(3)}, limit: "depth"},
+		{name: "nodes", opts: []Option{WithMaxNodes(7)}, limit: "nodes"},
+		{name: "args", opts: []Option{WithMaxArgs(1)}, limit: "args"},
+		{name: "list length", opts: []Option{WithMaxListLength(2)}, limit: "list length"},
+		{name: "within limits", opts: []Option{WithMaxDepth(4), WithMaxNodes(8), WithMaxArgs(2), WithMaxListLength(3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			_, _, err := NewPredicateBuilder(tt.opts...).BuildPredicate(e)
+			if tt.limit == "" {
+				is.NoError(err)
+				return
+			}
+			is.ErrorIs(err, ErrLimitExceeded)
+			var le *LimitError
+			is.True(errors.As(err, &le))
+			is.Equal(tt.limit, le.Limit)
+		})
+	}
+}
+
+func Test_LimitsBeforeRewriting(t *testing.T) {
+	is := require.New(t)
+	// The "or" merges into a single "in", which would take 3 nodes instead of 7.
+	chain := mustExpression(t, `{"expression":{"operator":"or","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.department"},{"value":"Sales"}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.department"},{"value":"IT"}]}}]}}`)
+	_, _, err := NewPredicateBuilder(WithMaxNodes(6)).BuildPredicate(chain)
+	var le *LimitError
+	is.True(errors.As(err, &le))
+	is.Equal("nodes", le.Limit)
+	_, _, err = NewPredicateBuilder(WithMaxNodes(7)).BuildPredicate(chain)
+	is.NoError(err)
+
+	// A plan deeper than the default limit is rejected before it is resolved or canonicalized.
+	deep := `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`
+	for i := 0; i < DefaultMaxDepth; i++ {
+		deep = `{"expression":{"operator":"not","operands":[` + deep + `]}}`
+	}
+	_, _, err = NewPredicateBuilder(WithCanonicalShape(), WithPrincipalAttrs(map[string]any{})).BuildPredicate(mustExpression(t, deep))
+	is.True(errors.As(err, &le))
+	is.Equal("depth", le.Limit)
+	_, _, err = NewPredicateBuilder(WithMaxDepth(0)).BuildPredicate(mustExpression(t, deep))
+	is.NoError(err)
+}
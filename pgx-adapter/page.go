@@ -0,0 +1,288 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/georgysavva/scany/v2/pgxscan"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not returned by NextPage for the same sort keys.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortKey is a column of the order of the pages.
+type SortKey struct {
+	// Column is the name of the column or of the resource attribute, e.g. "created_at" or "createdAt".
+	Column string
+	Desc   bool
+}
+
+// Page selects a page of the rows matching a plan.
+type Page struct {
+	// SortKeys order the rows. The columns cannot be NULL and the last one must be unique, e.g. the primary key,
+	// so that every row is on exactly one page.
+	SortKeys []SortKey
+	// Size is the maximum number of rows of the page.
+	Size int
+	// Cursor is the cursor returned by NextPage for the previous page, or empty for the first page.
+	Cursor string
+}
+
+// BuildPageQuery appends the clauses selecting a page of the rows matching the plan filter to query,
+// e.g. SELECT * FROM contacts WHERE ("owner_id" = $1) AND ("created_at", "id") > ($2, $3)
+// ORDER BY "created_at", "id" LIMIT 21. The query requests one row more than the size of the page,
+// which NextPage uses to tell whether there is a next page.
+// Sort keys in the same direction are compared as a row, mixed directions are compared column by column.
+func BuildPageQuery(query string, filter *enginev1.PlanResourcesFilter, page Page, opts ...Option) (string, []interface{}, error) {
+	return buildPageQuery(query, filter, page, newOptions(opts...))
+}
+
+func buildPageQuery(query string, filter *enginev1.PlanResourcesFilter, page Page, o *options) (string, []interface{}, error) {
+	if len(page.SortKeys) == 0 {
+		return "", nil, errors.New("page: no sort keys")
+	}
+	if page.Size <= 0 {
+		return "", nil, fmt.Errorf("page: invalid size %d", page.Size)
+	}
+	columns := make([]string, len(page.SortKeys))
+	for i, key := range page.SortKeys {
+		column, err := o.column(key.Column)
+		if err != nil {
+			return "", nil, fmt.Errorf("sort key %q: %w", key.Column, err)
+		}
+		columns[i] = column
+	}
+
+	where, args, err := filterWhere(filter, o)
+	if err != nil {
+		return "", nil, err
+	}
+	var conditions []string
+	switch {
+	case where == "":
+	case filter.Kind == enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		conditions = append(conditions, "("+where+")")
+	default:
+		conditions = append(conditions, where)
+	}
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, page.SortKeys)
+		if err != nil {
+			return "", nil, err
+		}
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, keysetCondition(page.SortKeys, columns, placeholders))
+	}
+
+	b := new(strings.Builder)
+	b.WriteString(query)
+	for i, c := range conditions {
+		if i == 0 {
+			b.WriteString(" WHERE ")
+		} else {
+			b.WriteString(" AND ")
+		}
+		b.WriteString(c)
+	}
+	b.WriteString(" ORDER BY ")
+	for i, key := range page.SortKeys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(columns[i])
+		if key.Desc {
+			b.WriteString(" DESC")
+		}
+	}
+	fmt.Fprintf(b, " LIMIT %d", page.Size+1)
+	return b.String(), args, nil
+}
+
+// keysetCondition returns the condition selecting the rows after the one whose sort key values are the placeholders.
+func keysetCondition(keys []SortKey, columns, placeholders []string) string {
+	op := func(key SortKey) string {
+		if key.Desc {
+			return " < "
+		}
+		return " > "
+	}
+	uniform := true
+	for _, key := range keys[1:] {
+		uniform = uniform && key.Desc == keys[0].Desc
+	}
+	if len(keys) == 1 {
+		return columns[0] + op(keys[0]) + placeholders[0]
+	}
+	if uniform {
+		return "(" + strings.Join(columns, ", ") + ")" + op(keys[0]) + "(" + strings.Join(placeholders, ", ") + ")"
+	}
+	// (a > $1) OR (a = $1 AND b < $2) OR (a = $1 AND b = $2 AND c > $3) ...
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		var t []string
+		for j := 0; j < i; j++ {
+			t = append(t, columns[j]+" = "+placeholders[j])
+		}
+		t = append(t, columns[i]+op(key)+placeholders[i])
+		terms[i] = "(" + strings.Join(t, " AND ") + ")"
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// NextPage removes the extra row requested by BuildPageQuery from the rows of a page and returns the cursor
+// of the next page, or an empty cursor if there is none. The sort key values are read from the fields of
+// the last row, which is a struct such as db.Contact or a pointer to one, the same way as StructAttrs.
+// Pass the options given to BuildPageQuery, so that a sort key is read from the column the query orders on.
+func NextPage[T any](rows []T, page Page, opts ...Option) ([]T, string, error) {
+	return nextPage(rows, page, newOptions(opts...))
+}
+
+func nextPage[T any](rows []T, page Page, o *options) ([]T, string, error) {
+	if len(rows) <= page.Size {
+		return rows, "", nil
+	}
+	rows = rows[:page.Size]
+	attrs, err := StructAttrs(rows[len(rows)-1])
+	if err != nil {
+		return nil, "", err
+	}
+	values := make([]any, len(page.SortKeys))
+	for i, key := range page.SortKeys {
+		// The row has the column of a schema-qualified name, e.g. owner_id for cerbforce.contacts.owner_id.
+		name := o.fieldName(key.Column)
+		v, ok := attrs[name[strings.LastIndex(name, ".")+1:]]
+		if !ok {
+			return nil, "", fmt.Errorf("sort key %q: no such field in %T", key.Column, rows[0])
+		}
+		values[i] = v
+	}
+	cursor, err := encodeCursor(page.SortKeys, values)
+	if err != nil {
+		return nil, "", err
+	}
+	return rows, cursor, nil
+}
+
+// QueryPage runs the query of BuildPageQuery and returns the rows of the page along with the cursor of the next page.
+// A plan that is always denied returns no rows without running the query.
+func QueryPage[T any](ctx context.Context, db pgxscan.Querier, query string, filter *enginev1.PlanResourcesFilter, page Page, opts ...Option) ([]T, string, error) {
+	if filter.GetKind() == enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED {
+		return nil, "", nil
+	}
+	sql, args, err := BuildPageQuery(query, filter, page, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	var rows []T
+	if err := pgxscan.Select(ctx, db, &rows, sql, args...); err != nil {
+		return nil, "", err
+	}
+	return NextPage(rows, page, opts...)
+}
+
+// cursor is the JSON form of a cursor. Keys are the sort keys the cursor was created for, with a "-" prefix if descending.
+type cursor struct {
+	Keys   []string      `json:"k"`
+	Values []cursorValue `json:"v"`
+}
+
+// cursorValue holds a sort key value along with its type, so that it decodes to the type it was encoded from.
+type cursorValue struct {
+	Time   *time.Time `json:"t,omitempty"`
+	Int    *int64     `json:"i,omitempty"`
+	Float  *float64   `json:"f,omitempty"`
+	String *string    `json:"s,omitempty"`
+	Bool   *bool      `json:"b,omitempty"`
+}
+
+func cursorKeys(keys []SortKey) []string {
+	res := make([]string, len(keys))
+	for i, key := range keys {
+		res[i] = key.Column
+		if key.Desc {
+			res[i] = "-" + key.Column
+		}
+	}
+	return res
+}
+
+func encodeCursor(keys []SortKey, values []any) (string, error) {
+	c := cursor{Keys: cursorKeys(keys), Values: make([]cursorValue, len(values))}
+	for i, v := range values {
+		switch x := v.(type) {
+		case time.Time:
+			c.Values[i].Time = &x
+		case string:
+			c.Values[i].String = &x
+		case bool:
+			c.Values[i].Bool = &x
+		default:
+			rv := reflect.ValueOf(v)
+			switch {
+			case rv.CanInt():
+				n := rv.Int()
+				c.Values[i].Int = &n
+			case rv.CanUint() && rv.Uint() <= 1<<63-1:
+				n := int64(rv.Uint())
+				c.Values[i].Int = &n
+			case rv.CanFloat():
+				f := rv.Float()
+				c.Values[i].Float = &f
+			default:
+				return "", fmt.Errorf("sort key %q: unsupported value %v of type %T", keys[i].Column, v, v)
+			}
+		}
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string, keys []SortKey) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if !slices.Equal(c.Keys, cursorKeys(keys)) || len(c.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: created for the sort keys %v", ErrInvalidCursor, c.Keys)
+	}
+	values := make([]any, len(c.Values))
+	for i, v := range c.Values {
+		switch {
+		case v.Time != nil:
+			values[i] = *v.Time
+		case v.Int != nil:
+			values[i] = *v.Int
+		case v.Float != nil:
+			values[i] = *v.Float
+		case v.String != nil:
+			values[i] = *v.String
+		case v.Bool != nil:
+			values[i] = *v.Bool
+		default:
+			return nil, fmt.Errorf("%w: missing value for sort key %q", ErrInvalidCursor, keys[i].Column)
+		}
+	}
+	return values, nil
+}
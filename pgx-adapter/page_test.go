@@ -0,0 +1,209 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cerbos/cerbos-go-adapters/pgx-adapter/db"
+)
+
+func Test_BuildPageQuery(t *testing.T) {
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)
+	byCreatedAt := []SortKey{{Column: "createdAt"}, {Column: "id"}}
+	mixed := []SortKey{{Column: "lastName"}, {Column: "createdAt", Desc: true}, {Column: "id"}}
+	mustCursor := func(keys []SortKey, values ...any) string {
+		c, err := encodeCursor(keys, values)
+		require.NoError(t, err)
+		return c
+	}
+	ownerFilter := &enginev1.PlanResourcesFilter{
+		Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`)},
+	}
+	tests := []struct {
+		name   string
+		filter *enginev1.PlanResourcesFilter
+		page   Page
+		want   string
+		args   []interface{}
+	}{
+		{
+			name:   "first page",
+			filter: ownerFilter,
+			page:   Page{SortKeys: byCreatedAt, Size: 20},
+			want:   `SELECT * FROM contacts WHERE ("owner_id" = $1) ORDER BY "created_at", "id" LIMIT 21`,
+			args:   []interface{}{"2"},
+		},
+		{
+			name:   "next page",
+			filter: ownerFilter,
+			page:   Page{SortKeys: byCreatedAt, Size: 20, Cursor: mustCursor(byCreatedAt, createdAt, 7)},
+			want:   `SELECT * FROM contacts WHERE ("owner_id" = $1) AND ("created_at", "id") > ($2, $3) ORDER BY "created_at", "id" LIMIT 21`,
+			args:   []interface{}{"2", createdAt, int64(7)},
+		},
+		{
+			name:   "descending",
+			filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED},
+			page:   Page{SortKeys: []SortKey{{Column: "id", Desc: true}}, Size: 5, Cursor: mustCursor([]SortKey{{Column: "id", Desc: true}}, 7)},
+			want:   `SELECT * FROM contacts WHERE "id" < $1 ORDER BY "id" DESC LIMIT 6`,
+			args:   []interface{}{int64(7)},
+		},
+		{
+			name:   "mixed directions",
+			filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED},
+			page:   Page{SortKeys: mixed, Size: 5, Cursor: mustCursor(mixed, "Doe", createdAt, 7)},
+			want: `SELECT * FROM contacts WHERE (("last_name" > $1) OR ("last_name" = $1 AND "created_at" < $2) OR ("last_name" = $1 AND "created_at" = $2 AND "id" > $3))` +
+				` ORDER BY "last_name", "created_at" DESC, "id" LIMIT 6`,
+			args: []interface{}{"Doe", createdAt, int64(7)},
+		},
+		{
+			name:   "always denied",
+			filter: &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED},
+			page:   Page{SortKeys: byCreatedAt, Size: 20},
+			want:   `SELECT * FROM contacts WHERE FALSE ORDER BY "created_at", "id" LIMIT 21`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			query, args, err := BuildPageQuery("SELECT * FROM contacts", tt.filter, tt.page)
+			is.NoError(err)
+			is.Equal(tt.want, query)
+			is.Equal(tt.args, args)
+		})
+	}
+}
+
+func Test_BuildPageQueryInvalid(t *testing.T) {
+	allowed := &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED}
+	keys := []SortKey{{Column: "createdAt"}, {Column: "id"}}
+	cursor, err := encodeCursor(keys, []any{time.Now(), 1})
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		page Page
+		opts []Option
+		err  error
+	}{
+		{name: "no sort keys", page: Page{Size: 1}},
+		{name: "no size", page: Page{SortKeys: keys}},
+		{name: "column not allowed", page: Page{SortKeys: keys, Size: 1}, opts: []Option{WithAllowedColumns("id")}, err: ErrColumnNotAllowed},
+		{name: "garbage cursor", page: Page{SortKeys: keys, Size: 1, Cursor: "not a cursor"}, err: ErrInvalidCursor},
+		{name: "other sort keys", page: Page{SortKeys: keys[1:], Size: 1, Cursor: cursor}, err: ErrInvalidCursor},
+		{name: "other direction", page: Page{SortKeys: []SortKey{{Column: "createdAt", Desc: true}, {Column: "id"}}, Size: 1, Cursor: cursor}, err: ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := BuildPageQuery("SELECT * FROM contacts", allowed, tt.page, tt.opts...)
+			require.Error(t, err)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func Test_NextPage(t *testing.T) {
+	is := require.New(t)
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)
+	contacts := []*db.Contact{{ID: 1, CreatedAt: createdAt}, {ID: 2, CreatedAt: createdAt}, {ID: 3, CreatedAt: createdAt}}
+	page := Page{SortKeys: []SortKey{{Column: "createdAt"}, {Column: "id"}}, Size: 2}
+
+	rows, next, err := NextPage(contacts, page)
+	is.NoError(err)
+	is.Equal(contacts[:2], rows)
+	values, err := decodeCursor(next, page.SortKeys)
+	is.NoError(err)
+	is.Equal([]any{createdAt, int64(2)}, values)
+
+	rows, next, err = NextPage(contacts[:2], page)
+	is.NoError(err)
+	is.Equal(contacts[:2], rows)
+	is.Empty(next)
+
+	_, _, err = NextPage(contacts, Page{SortKeys: []SortKey{{Column: "rank"}}, Size: 2})
+	is.Error(err)
+}
+
+func Test_NextPageFieldNames(t *testing.T) {
+	is := require.New(t)
+	opts := []Option{WithFieldNames(map[string]string{"rank": "owner_id", "key": "cerbforce.contacts.id"}), WithTableAlias("c")}
+	contacts := []*db.Contact{{ID: 1, OwnerID: 7}, {ID: 2, OwnerID: 8}, {ID: 3, OwnerID: 9}}
+	page := Page{SortKeys: []SortKey{{Column: "rank"}, {Column: "key"}}, Size: 2}
+	allowed := &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED}
+
+	sql, _, err := BuildPageQuery("SELECT * FROM contacts c", allowed, page, opts...)
+	is.NoError(err)
+	is.Contains(sql, `ORDER BY "c"."owner_id", "cerbforce"."contacts"."id"`)
+
+	rows, next, err := NextPage(contacts, page, opts...)
+	is.NoError(err)
+	is.Equal(contacts[:2], rows)
+	values, err := decodeCursor(next, page.SortKeys)
+	is.NoError(err)
+	is.Equal([]any{int64(8), int64(2)}, values)
+
+	page.Cursor = next
+	sql, args, err := BuildPageQuery("SELECT * FROM contacts c", allowed, page, opts...)
+	is.NoError(err)
+	is.Contains(sql, `("c"."owner_id", "cerbforce"."contacts"."id") > ($1, $2)`)
+	is.Equal([]any{int64(8), int64(2)}, args)
+
+	// Without the options the cursor would read the columns named after the sort keys, which the row does not have.
+	_, _, err = NextPage(contacts, page)
+	is.Error(err)
+}
+
+func TestQueryPageIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires Postgres")
+	}
+	ctx := context.Background()
+	repo := startDatabase(ctx, t)
+	filter := &enginev1.PlanResourcesFilter{
+		Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"or","operands":[
+			{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+			{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"1"}]}}]}}`)},
+	}
+	all, err := repo.GetContacts(ctx, filter)
+	require.NoError(t, err)
+	want := getNames(all)
+	sort.Strings(want)
+
+	for _, keys := range [][]SortKey{
+		{{Column: "createdAt"}, {Column: "id"}},
+		{{Column: "lastName", Desc: true}, {Column: "id", Desc: true}},
+		{{Column: "active"}, {Column: "firstName", Desc: true}, {Column: "id"}},
+	} {
+		page := Page{SortKeys: keys, Size: 2}
+		var got []string
+		for i := 0; ; i++ {
+			require.Less(t, i, len(want)+1, "too many pages")
+			rows, next, err := QueryPage[*db.Contact](ctx, repo.Conn(), "SELECT * FROM contacts", filter, page)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(rows), page.Size)
+			got = append(got, getNames(rows)...)
+			if next == "" {
+				break
+			}
+			page.Cursor = next
+		}
+		sort.Strings(got)
+		require.Equal(t, want, got, "sort keys %v", keys)
+	}
+
+	rows, next, err := QueryPage[*db.Contact](ctx, repo.Conn(), "SELECT * FROM contacts",
+		&enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED}, Page{SortKeys: []SortKey{{Column: "id"}}, Size: 2})
+	require.NoError(t, err)
+	require.Empty(t, rows)
+	require.Empty(t, next)
+}
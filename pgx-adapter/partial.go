@@ -0,0 +1,179 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+)
+
+// PartialPredicate is the result of BuildPartialPredicate.
+type PartialPredicate struct {
+	// Where is the SQL of the part of the plan that can be translated. It is empty if every row may match.
+	Where string
+	Args  []interface{}
+	// Residual is the predicate the rows returned by Where must also satisfy, made of the conjuncts of the plan
+	// that Where does not translate exactly. It is nil if Where is exact.
+	Residual Predicate
+}
+
+// BuildPartialPredicate translates a plan that BuildPredicate may not be able to translate as a whole.
+// The expressions that fail with ErrUnsupported, e.g. an operator without an SQL equivalent, are replaced by
+// true or false depending on whether they appear under a negation, so that Where matches at least the rows
+// matched by the plan. The conjuncts of the plan that contain such expressions are evaluated on the rows returned
+// by the query with the Residual predicate, the others are left to the database.
+// A plan that can be translated as a whole gives the same SQL as BuildPredicate and no Residual.
+// If the plan cannot be evaluated in memory either, the translation error is returned.
+func BuildPartialPredicate(e *filterOpExpression, opts ...Option) (*PartialPredicate, error) {
+	return buildPartialPredicate(e, newOptions(opts...))
+}
+
+func buildPartialPredicate(e *filterOpExpression, o *options) (*PartialPredicate, error) {
+	where, args, err := buildPredicate(e, o)
+	if err == nil {
+		return &PartialPredicate{Where: where, Args: args}, nil
+	}
+	if !errors.Is(err, ErrUnsupported) {
+		return nil, err
+	}
+	resolved, rerr := o.resolve(e)
+	if rerr != nil || hasBinding(&filterOp{Node: resolved}) {
+		// The bindings left by resolve are SQL, which the evaluator cannot run.
+		return nil, err
+	}
+
+	// The conjuncts that can be translated are exact in Where, so the residual leaves them out.
+	conjuncts := []*filterOp{{Node: resolved}}
+	if resolved.Expression.Operator == "and" {
+		conjuncts = resolved.Expression.Operands
+	}
+	var pushed, rest []*filterOp
+	for _, c := range conjuncts {
+		if ce, ok := c.GetNode().(*filterOpExpression); ok && translatable(ce, o) {
+			pushed = append(pushed, c)
+			continue
+		}
+		pushed = append(pushed, approximate(c, true, o))
+		rest = append(rest, c)
+	}
+	if len(rest) == 0 {
+		return nil, err
+	}
+	re, ok := rest[0].GetNode().(*filterOpExpression)
+	if len(rest) > 1 {
+		re, ok = newExpression("and", rest...).GetNode().(*filterOpExpression)
+	}
+	if !ok {
+		return nil, err
+	}
+	residual, cerr := compile(re, o)
+	if cerr != nil {
+		return nil, err
+	}
+
+	p := &PartialPredicate{Residual: residual}
+	approx := simplifyLogical("and", pushed)
+	if b, ok := boolLiteral(approx); ok {
+		if !b {
+			p.Where = "FALSE"
+		}
+		return p, nil
+	}
+	ae, ok := approx.GetNode().(*filterOpExpression)
+	if !ok {
+		return nil, ErrExpressionExpected
+	}
+	if p.Where, p.Args, err = buildPredicate(ae, o); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// approximate returns the plan o with the expressions that cannot be translated replaced by the literal over.
+// If over is true, the result is true wherever o is true; otherwise it is false wherever o is false, which is
+// what a negation needs for its operand. Both hold under SQL three-valued logic, where NULL is neither.
+// The literals are folded into the logical operators, e.g. a AND TRUE becomes a, but unlike Simplify,
+// the comparisons are left as they are.
+func approximate(o *filterOp, over bool, opts *options) *filterOp {
+	e, ok := o.GetNode().(*filterOpExpression)
+	if !ok {
+		return o
+	}
+	switch operator := e.Expression.Operator; operator {
+	case "and", "or", "not":
+		if operator == "not" {
+			over = !over
+		}
+		operands := make([]*filterOp, len(e.Expression.Operands))
+		for i, operand := range e.Expression.Operands {
+			operands[i] = approximate(operand, over, opts)
+		}
+		if operator != "not" {
+			return simplifyLogical(operator, operands)
+		}
+		if len(operands) == 1 {
+			if b, ok := boolLiteral(operands[0]); ok {
+				return boolOperand(!b)
+			}
+		}
+		return newExpression(operator, operands...)
+	}
+	if translatable(e, opts) {
+		return o
+	}
+	return boolOperand(over)
+}
+
+// translatable reports whether e can be translated on its own, regardless of the limits of opts.
+func translatable(e *filterOpExpression, opts *options) bool {
+	o := *opts
+	o.maxDepth, o.maxNodes, o.maxArgs, o.maxListLength = 0, 0, 0, 0
+	o.explainer = nil
+	_, _, err := buildPredicate(e, &o)
+	return !errors.Is(err, ErrUnsupported)
+}
+
+// hasBinding reports whether the plan refers to a policy variable or a global.
+func hasBinding(o *filterOp) bool {
+	switch n := o.GetNode().(type) {
+	case *filterOpVariable:
+		_, ok := bindingName(n.Variable)
+		return ok
+	case *filterOpExpression:
+		for _, operand := range n.Expression.Operands {
+			if hasBinding(operand) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PartialPredicateBuilder is a predicate builder for db.New that falls back to BuildPartialPredicate,
+// so that the client filters the rows of the plans that cannot be translated as a whole.
+type PartialPredicateBuilder struct {
+	o *options
+}
+
+// NewPartialPredicateBuilder returns a PartialPredicateBuilder configured with the given options.
+func NewPartialPredicateBuilder(opts ...Option) *PartialPredicateBuilder {
+	return &PartialPredicateBuilder{o: newOptions(opts...)}
+}
+
+// BuildPredicate translates the plan as a whole, like BuildPredicate.
+func (b *PartialPredicateBuilder) BuildPredicate(e *filterOpExpression) (string, []interface{}, error) {
+	return buildPredicate(e, b.o)
+}
+
+// BuildPartialPredicate translates the plan like BuildPartialPredicate. The residual predicate,
+// if any, takes a struct such as db.Contact.
+func (b *PartialPredicateBuilder) BuildPartialPredicate(e *filterOpExpression) (where string, args []interface{}, residual func(any) (bool, error), err error) {
+	p, err := buildPartialPredicate(e, b.o)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if p.Residual != nil {
+		residual = p.Residual.EvalStruct
+	}
+	return p.Where, p.Args, residual, nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func Test_BuildPartialPredicate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		where    string
+		args     []interface{}
+		residual bool
+	}{
+		{
+			name:  "translatable",
+			input: `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`,
+			where: `"owner_id" = $1`,
+			args:  []interface{}{"2"},
+		},
+		{
+			name: "and",
+			input: `{"expression":{"operator":"and","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+				{"expression":{"operator":"startsWith","operands":[{"variable":"R.attr.firstName"},{"value":"Ni"}]}}]}}`,
+			where:    `"owner_id" = $1`,
+			args:     []interface{}{"2"},
+			residual: true,
+		},
+		{
+			name: "or",
+			input: `{"expression":{"operator":"or","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+				{"expression":{"operator":"startsWith","operands":[{"variable":"R.attr.firstName"},{"value":"Ni"}]}}]}}`,
+			residual: true,
+		},
+		{
+			name: "not and",
+			input: `{"expression":{"operator":"not","operands":[{"expression":{"operator":"and","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+				{"expression":{"operator":"startsWith","operands":[{"variable":"R.attr.firstName"},{"value":"Ni"}]}}]}}]}}`,
+			residual: true,
+		},
+		{
+			name: "not or",
+			input: `{"expression":{"operator":"not","operands":[{"expression":{"operator":"or","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+				{"expression":{"operator":"startsWith","operands":[{"variable":"R.attr.firstName"},{"value":"Ni"}]}}]}}]}}`,
+			where:    `NOT ("active" = $1)`,
+			args:     []interface{}{true},
+			residual: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			p, err := BuildPartialPredicate(mustExpression(t, tt.input))
+			is.NoError(err)
+			is.Equal(tt.where, p.Where)
+			is.Equal(tt.args, p.Args)
+			is.Equal(tt.residual, p.Residual != nil)
+		})
+	}
+}
+
+func Test_BuildPartialPredicateUnsupported(t *testing.T) {
+	is := require.New(t)
+	_, err := BuildPartialPredicate(mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"matches","operands":[{"variable":"R.attr.firstName"},{"value":"^N"}]}}]}}`))
+	is.ErrorIs(err, ErrUnsupported)
+}
+
+func Test_BuildPartialPredicateResidual(t *testing.T) {
+	is := require.New(t)
+	p, err := BuildPartialPredicate(mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+		{"expression":{"operator":"startsWith","operands":[{"variable":"R.attr.firstName"},{"value":"Ni"}]}}]}}`))
+	is.NoError(err)
+	is.Equal(`("owner_id" = $1) AND ("active" = $2)`, p.Where)
+	// The residual only evaluates the startsWith conjunct, the others are checked by the database.
+	ok, err := p.Residual(map[string]any{"first_name": "Nick"})
+	is.NoError(err)
+	is.True(ok)
+	ok, err = p.Residual(map[string]any{"first_name": "Simon", "owner_id": 2, "active": true})
+	is.NoError(err)
+	is.False(ok)
+}
+
+func Test_BuildPartialPredicateFieldNames(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"and","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"startsWith","operands":[{"variable":"R.attr.firstName"},{"value":"Ni"}]}}]}}`)
+	fieldNames := WithFieldNames(map[string]string{"ownerId": "owner", "firstName": "given_name"})
+
+	p, err := BuildPartialPredicate(e, fieldNames, WithTableAlias("c"))
+	is.NoError(err)
+	is.Equal(`"c"."owner" = $1`, p.Where)
+	// The residual reads the mapped column, without the table alias, as the rows of the query are keyed.
+	ok, err := p.Residual(map[string]any{"given_name": "Nick", "first_name": "Simon"})
+	is.NoError(err)
+	is.True(ok)
+	ok, err = p.Residual(map[string]any{"given_name": "Simon", "first_name": "Nick"})
+	is.NoError(err)
+	is.False(ok)
+
+	// A residual column that is not allowed fails as it would in the SQL.
+	_, err = BuildPartialPredicate(e, fieldNames, WithAllowedColumns("owner"))
+	is.ErrorIs(err, ErrUnsupported)
+	_, err = BuildPartialPredicate(e, fieldNames, WithAllowedColumns("owner", "given_name"))
+	is.NoError(err)
+}
+
+// partialPlan returns a random plan in which some comparisons of genPlan are replaced by operators
+// that only the evaluator supports.
+func partialPlan(r *rand.Rand) *filterOp {
+	var replace func(o *filterOp) *filterOp
+	replace = func(o *filterOp) *filterOp {
+		e := o.GetExpression()
+		switch e.GetOperator() {
+		case "and", "or", "not":
+			operands := make([]*filterOp, len(e.Operands))
+			for i, operand := range e.Operands {
+				operands[i] = replace(operand)
+			}
+			return newExpression(e.Operator, operands...)
+		}
+		if r.Intn(3) > 0 { //nolint:gomnd
+			return o
+		}
+		operator := []string{"startsWith", "endsWith", "contains"}[r.Intn(3)] //nolint:gomnd
+		variable := []string{"firstName", "lastName"}[r.Intn(2)]
+		value := []string{"a", "e", "Ma", "N", "th"}[r.Intn(5)] //nolint:gomnd
+		return newExpression(operator,
+			&filterOp{Node: &filterOpVariable{Variable: "request.resource.attr." + variable}},
+			&filterOp{Node: &filterOpValue{Value: structpb.NewStringValue(value)}})
+	}
+	return replace(genPlan(r, 4)) //nolint:gomnd
+}
+
+// Test_ApproximateOverApproximates checks that the approximation of a plan can be translated
+// and matches every contact matched by the plan.
+func Test_ApproximateOverApproximates(t *testing.T) {
+	contacts := seedContacts(t)
+	r := rand.New(rand.NewSource(*diffSeed)) //nolint:gosec
+	for i := 0; i < *diffCount; i++ {
+		plan := partialPlan(r)
+		p, err := Compile(plan.GetNode().(*filterOpExpression))
+		require.NoError(t, err)
+		approx := approximate(plan, true, newOptions())
+		var q Predicate
+		if b, ok := boolLiteral(approx); ok {
+			q = func(map[string]any) (bool, error) { return b, nil }
+		} else {
+			ae := approx.GetNode().(*filterOpExpression)
+			_, _, err = BuildPredicate(ae)
+			require.NoError(t, err, protojson.Format(approx))
+			q, err = Compile(ae)
+			require.NoError(t, err)
+		}
+		for name, attrs := range contacts {
+			matched, err := p(columnAttrs(attrs))
+			require.NoError(t, err)
+			approximated, err := q(columnAttrs(attrs))
+			require.NoError(t, err)
+			require.True(t, approximated || !matched, "plan %d of seed %d matches %s but its approximation does not\nplan: %s\napproximation: %s",
+				i, *diffSeed, name, protojson.Format(plan), protojson.Format(approx))
+		}
+	}
+}
+
+func TestPartialPredicateIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires Postgres")
+	}
+	ctx := context.Background()
+	repo := startDatabaseWith(ctx, t, NewPartialPredicateBuilder())
+	contacts := seedContacts(t)
+	r := rand.New(rand.NewSource(*diffSeed)) //nolint:gosec
+	for i := 0; i < *diffCount; i++ {
+		plan := partialPlan(r)
+		e := plan.GetNode().(*filterOpExpression)
+		p, err := Compile(e)
+		require.NoError(t, err)
+		var want []string
+		for name, attrs := range contacts {
+			ok, err := p(columnAttrs(attrs))
+			require.NoError(t, err)
+			if ok {
+				want = append(want, name)
+			}
+		}
+		res, err := repo.GetContacts(ctx, &enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL, Condition: plan})
+		require.NoError(t, err, protojson.Format(plan))
+		got := getNames(res)
+		sort.Strings(got)
+		sort.Strings(want)
+		require.Equal(t, want, got, "plan %d of seed %d: %s", i, *diffSeed, protojson.Format(plan))
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ErrUnresolvedPrincipalAttribute is wrapped by the PrincipalAttributeError returned when a plan refers to
+// a principal attribute that is not in the attributes given with WithPrincipalAttrs.
+var ErrUnresolvedPrincipalAttribute = errors.New("unresolved principal attribute")
+
+// PrincipalAttributeError names the principal attribute a plan refers to, e.g. "department" for P.attr.department.
+type PrincipalAttributeError struct {
+	Attr string
+}
+
+func (e *PrincipalAttributeError) Error() string {
+	return fmt.Sprintf("%s %q: the plan refers to the principal, not to a column", ErrUnresolvedPrincipalAttribute, e.Attr)
+}
+
+func (e *PrincipalAttributeError) Unwrap() error {
+	return ErrUnresolvedPrincipalAttribute
+}
+
+// WithPrincipalAttrs substitutes the request.principal.attr.* and P.attr.* variables left in a plan with the given values.
+// A plan referring to any other principal variable, or to an attribute missing from attrs, fails with a PrincipalAttributeError.
+func WithPrincipalAttrs(attrs map[string]any) Option {
+	return func(o *options) {
+		o.principalAttrs = attrs
+	}
+}
+
+// principalAttr returns the name of the principal attribute a variable refers to.
+// Principal variables other than attributes, e.g. P.id, are returned with their full path.
+func principalAttr(variable string) (string, bool) {
+	for _, prefix := range []string{"request.principal.attr.", "P.attr."} {
+		if name, ok := strings.CutPrefix(variable, prefix); ok {
+			return name, true
+		}
+	}
+	if strings.HasPrefix(variable, "request.principal.") || strings.HasPrefix(variable, "P.") {
+		return variable, true
+	}
+	return "", false
+}
+
+// resolvePrincipal returns the plan expression with the principal variables replaced by their values.
+// The expression is returned as is if it does not refer to the principal.
+func resolvePrincipal(e *filterOpExpression, attrs map[string]any) (*filterOpExpression, error) {
+	return substituteVariables(e, func(variable string) (*filterOp, error) {
+		name, ok := principalAttr(variable)
+		if !ok {
+			return nil, nil
+		}
+		v, ok := attrs[name]
+		if !ok {
+			return nil, &PrincipalAttributeError{Attr: name}
+		}
+		return valueOperand(fmt.Sprintf("principal attribute %q", name), v)
+	})
+}
+
+func valueOperand(what string, v any) (*filterOp, error) {
+	value, err := structpb.NewValue(structValue(v))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", what, err)
+	}
+	return &filterOp{Node: &filterOpValue{Value: value}}, nil
+}
+
+// structValue converts the typed slices and maps of v, e.g. []string or map[string]int, into the []any and
+// map[string]any that structpb.NewValue accepts. Other values are returned as they are.
+func structValue(v any) any {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if _, ok := v.([]byte); ok {
+			return v
+		}
+		list := make([]any, rv.Len())
+		for i := range list {
+			list[i] = structValue(rv.Index(i).Interface())
+		}
+		return list
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = structValue(iter.Value().Interface())
+		}
+		return m
+	}
+	return v
+}
+
+// substituteVariables returns the plan expression with the variables for which f returns an operand replaced by it.
+// The parts of the plan without any substitution are shared with the input.
+// An error returned by f is wrapped in a TranslationError locating the variable.
+func substituteVariables(e *filterOpExpression, f func(variable string) (*filterOp, error)) (*filterOpExpression, error) {
+	if e == nil {
+		return nil, nil
+	}
+	o, err := substituteOperand(&filterOp{Node: e}, f)
+	if err != nil {
+		return nil, err
+	}
+	return o.GetNode().(*filterOpExpression), nil
+}
+
+func substituteOperand(o *filterOp, f func(variable string) (*filterOp, error)) (*filterOp, error) {
+	switch n := o.GetNode().(type) {
+	case *filterOpVariable:
+		r, err := f(n.Variable)
+		if err != nil {
+			return o, operandError(categoryOf(err), "variable", err)
+		}
+		if r == nil {
+			return o, nil
+		}
+		return r, nil
+	case *filterOpExpression:
+		var operands []*filterOp
+		for i, operand := range n.Expression.Operands {
+			r, err := substituteOperand(operand, f)
+			if err != nil {
+				return nil, inOperand(err, n.Expression.Operator, i)
+			}
+			if r != operand && operands == nil {
+				operands = make([]*filterOp, len(n.Expression.Operands))
+				copy(operands, n.Expression.Operands[:i])
+			}
+			if operands != nil {
+				operands[i] = r
+			}
+		}
+		if operands == nil {
+			return o, nil
+		}
+		return newExpression(n.Expression.Operator, operands...), nil
+	}
+	return o, nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PrincipalAttrs(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"or","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.department"},{"variable":"P.attr.department"}]}},
+		{"expression":{"operator":"in","operands":[{"variable":"request.resource.attr.region"},{"variable":"request.principal.attr.regions"}]}}]}}`)
+
+	where, args, err := NewPredicateBuilder(WithPrincipalAttrs(map[string]any{
+		"department": "Sales",
+		"regions":    []any{"EMEA", "APAC"},
+	})).BuildPredicate(e)
+	is.NoError(err)
+	is.Equal(`("department" = $1) OR ("region" = ANY($2))`, where)
+	is.Equal([]interface{}{"Sales", []interface{}{"EMEA", "APAC"}}, args)
+
+	// Typed slices, e.g. from a decoded principal, are bound the same way.
+	where, args, err = NewPredicateBuilder(WithPrincipalAttrs(map[string]any{
+		"department": "Sales",
+		"regions":    []string{"EMEA", "APAC"},
+	})).BuildPredicate(e)
+	is.NoError(err)
+	is.Equal(`("department" = $1) OR ("region" = ANY($2))`, where)
+	is.Equal([]interface{}{"Sales", []interface{}{"EMEA", "APAC"}}, args)
+
+	_, _, err = NewPredicateBuilder(WithPrincipalAttrs(map[string]any{"department": "Sales"})).BuildPredicate(e)
+	is.ErrorIs(err, ErrUnresolvedPrincipalAttribute)
+	var pe *PrincipalAttributeError
+	is.True(errors.As(err, &pe))
+	is.Equal("regions", pe.Attr)
+
+	_, _, err = BuildPredicate(e)
+	is.ErrorIs(err, ErrUnresolvedPrincipalAttribute)
+
+	_, err = BuildSqlizer(mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"variable":"P.id"}]}}`))
+	is.True(errors.As(err, &pe))
+	is.Equal("P.id", pe.Attr)
+}
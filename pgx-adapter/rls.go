@@ -0,0 +1,222 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/jackc/pgx/v5"
+)
+
+// PrincipalSettingPrefix is the prefix of the session settings holding the principal attributes read by the
+// generated row level security policies, e.g. cerbos.principal.department.
+const PrincipalSettingPrefix = "cerbos.principal."
+
+// RLSPolicy describes a Postgres row level security policy generated by GenerateRLSPolicy.
+type RLSPolicy struct {
+	// Name is the name of the policy.
+	Name string
+	// Table is the table the policy applies to, optionally schema-qualified, e.g. cerbforce.contacts.
+	Table string
+	// Command is one of ALL, SELECT, INSERT, UPDATE or DELETE. Defaults to SELECT.
+	Command string
+	// Roles are the database roles the policy applies to. Defaults to PUBLIC.
+	Roles []string
+	// SettingTypes maps principal attributes to the SQL type their setting is cast to, e.g. "companyId": "int".
+	// The type must be one of settingTypes. Settings are text otherwise.
+	SettingTypes map[string]string
+}
+
+// settingTypes are the types a principal setting can be cast to. The type is written into the policy as it is,
+// so it cannot be an arbitrary string.
+var settingTypes = map[string]struct{}{
+	"text": {}, "varchar": {}, "uuid": {}, "bool": {}, "boolean": {},
+	"int": {}, "integer": {}, "int2": {}, "int4": {}, "int8": {}, "smallint": {}, "bigint": {},
+	"numeric": {}, "real": {}, "float4": {}, "float8": {}, "double precision": {},
+	"date": {}, "timestamp": {}, "timestamptz": {},
+}
+
+// GenerateRLSPolicy returns a CREATE POLICY statement restricting the rows of a table to those allowed by a plan.
+// The plan must be computed for a role with the principal attributes left unresolved, so that they appear as
+// request.principal.attr.* or P.attr.* variables; these are read from the current_setting('cerbos.principal.<attr>')
+// of the session, see SetPrincipalSettings. Literal values are inlined, because a policy cannot have parameters.
+// Row level security must be enabled on the table with ALTER TABLE ... ENABLE ROW LEVEL SECURITY.
+func GenerateRLSPolicy(p RLSPolicy, filter *enginev1.PlanResourcesFilter, opts ...Option) (string, error) {
+	if filter == nil {
+		return "", errors.New("\"filter\" is nil")
+	}
+	if p.Name == "" || p.Table == "" {
+		return "", errors.New("policy name and table are required")
+	}
+	command := strings.ToUpper(p.Command)
+	switch command {
+	case "":
+		command = "SELECT"
+	case "ALL", "SELECT", "INSERT", "UPDATE", "DELETE":
+	default:
+		return "", fmt.Errorf("unsupported command %q", p.Command)
+	}
+	settings := make(map[string]string, len(p.SettingTypes))
+	for name, t := range p.SettingTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if _, ok := settingTypes[t]; !ok {
+			return "", fmt.Errorf("principal attribute %q: unsupported setting type %q", name, p.SettingTypes[name])
+		}
+		settings[name] = t
+	}
+
+	var using string
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		using = "true"
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		using = "false"
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		e, ok := filter.Condition.GetNode().(*filterOpExpression)
+		if !ok {
+			return "", ErrExpressionExpected
+		}
+		o := newOptions(opts...)
+		o.inlineValues = true
+		o.principalSettings = settings
+		var err error
+		if using, _, err = buildPredicate(e, o); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+
+	b := new(strings.Builder)
+	b.WriteString("CREATE POLICY ")
+	b.WriteString(pgx.Identifier{p.Name}.Sanitize())
+	b.WriteString(" ON ")
+	b.WriteString(quoteIdentifier(p.Table))
+	b.WriteString(" FOR ")
+	b.WriteString(command)
+	b.WriteString(" TO ")
+	if len(p.Roles) == 0 {
+		b.WriteString("PUBLIC")
+	}
+	for i, r := range p.Roles {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(pgx.Identifier{r}.Sanitize())
+	}
+	if command != "INSERT" {
+		b.WriteString(" USING (")
+		b.WriteString(using)
+		b.WriteRune(')')
+	}
+	if command == "ALL" || command == "INSERT" || command == "UPDATE" {
+		b.WriteString(" WITH CHECK (")
+		b.WriteString(using)
+		b.WriteRune(')')
+	}
+	b.WriteRune(';')
+	return b.String(), nil
+}
+
+// principalSetting returns the SQL reading the session setting of a principal attribute.
+// The setting is read with missing_ok, so an unset attribute is NULL and matches nothing. A setting that was set in
+// an earlier transaction of the session reads as an empty string once reset, which is turned into NULL as well,
+// before the cast that would otherwise fail.
+func (o *options) principalSetting(variable string) (string, error) {
+	name, _ := principalAttr(variable)
+	if strings.HasPrefix(name, "request.principal.") || strings.HasPrefix(name, "P.") {
+		return "", &PrincipalAttributeError{Attr: name}
+	}
+	s := "NULLIF(current_setting(" + sqlString(PrincipalSettingPrefix+name) + ", true), '')"
+	if t, ok := o.principalSettings[name]; ok {
+		s = "(" + s + ")::" + t
+	}
+	return s, nil
+}
+
+// SetPrincipalSettings sets the session settings read by the policies of GenerateRLSPolicy for the current transaction.
+// Lists are not supported, because a setting holds a single value.
+func SetPrincipalSettings(ctx context.Context, tx pgx.Tx, attrs map[string]any) error {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var value string
+		switch v := normalize(attrs[name]).(type) {
+		case nil:
+			continue
+		case string:
+			value = v
+		case bool:
+			value = strconv.FormatBool(v)
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Errorf("principal attribute %q: unsupported type %T", name, v)
+		}
+		if _, err := tx.Exec(ctx, "SELECT set_config($1, $2, true)", PrincipalSettingPrefix+name, value); err != nil {
+			return fmt.Errorf("failed to set principal attribute %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// WithPrincipalSettings runs f in a transaction with the session settings of the principal attributes set.
+// db is typically a *pgx.Conn or a *pgxpool.Pool.
+func WithPrincipalSettings(ctx context.Context, db txBeginner, attrs map[string]any, f func(pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if err := SetPrincipalSettings(ctx, tx, attrs); err != nil {
+			return err
+		}
+		return f(tx)
+	})
+}
+
+// sqlLiteral renders a plan value as an SQL literal.
+func sqlLiteral(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if x {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case string:
+		return sqlString(x), nil
+	case []any:
+		if len(x) == 0 {
+			return "'{}'", nil
+		}
+		items := make([]string, len(x))
+		for i, item := range x {
+			s, err := sqlLiteral(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "ARRAY[" + strings.Join(items, ", ") + "]", nil
+	}
+	return "", fmt.Errorf("cannot render %T as an SQL literal", v)
+}
+
+// sqlString quotes a string literal. Backslashes need no escaping, since standard_conforming_strings is on by default.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, "\x00", ""), "'", "''") + "'"
+}
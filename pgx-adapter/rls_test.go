@@ -0,0 +1,52 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GenerateRLSPolicy(t *testing.T) {
+	is := require.New(t)
+	filter := &enginev1.PlanResourcesFilter{
+		Kind: enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"or","operands":[
+			{"expression":{"operator":"eq","operands":[{"variable":"R.attr.companyId"},{"variable":"P.attr.companyId"}]}},
+			{"expression":{"operator":"and","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+				{"expression":{"operator":"in","operands":[{"variable":"R.attr.lastName"},{"value":["O'Brien","Smith"]}]}}]}}]}}`)},
+	}
+
+	stmt, err := GenerateRLSPolicy(RLSPolicy{
+		Name:         "contacts_user",
+		Table:        "cerbforce.contacts",
+		Roles:        []string{"cerbforce_user"},
+		SettingTypes: map[string]string{"companyId": "int"},
+	}, filter)
+	is.NoError(err)
+	is.Equal(`CREATE POLICY "contacts_user" ON "cerbforce"."contacts" FOR SELECT TO "cerbforce_user" USING (`+
+		`("company_id" = (NULLIF(current_setting('cerbos.principal.companyId', true), ''))::int) OR `+
+		`(("active" = TRUE) AND ("last_name" = ANY(ARRAY['O''Brien', 'Smith']))));`, stmt)
+
+	stmt, err = GenerateRLSPolicy(RLSPolicy{Name: "contacts_admin", Table: "cerbforce.contacts", Command: "all"},
+		&enginev1.PlanResourcesFilter{Kind: enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED})
+	is.NoError(err)
+	is.Equal(`CREATE POLICY "contacts_admin" ON "cerbforce"."contacts" FOR ALL TO PUBLIC USING (true) WITH CHECK (true);`, stmt)
+
+	_, err = GenerateRLSPolicy(RLSPolicy{Name: "p", Table: "contacts"}, &enginev1.PlanResourcesFilter{
+		Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: &filterOp{Node: mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"variable":"P.id"}]}}`)},
+	})
+	is.ErrorIs(err, ErrUnresolvedPrincipalAttribute)
+
+	stmt, err = GenerateRLSPolicy(RLSPolicy{Name: "p", Table: "contacts", SettingTypes: map[string]string{"companyId": "BIGINT"}}, filter)
+	is.NoError(err)
+	is.Contains(stmt, `(NULLIF(current_setting('cerbos.principal.companyId', true), ''))::bigint`)
+
+	_, err = GenerateRLSPolicy(RLSPolicy{Name: "p", Table: "contacts", SettingTypes: map[string]string{"companyId": "int); DROP TABLE contacts; --"}}, filter)
+	is.ErrorContains(err, "unsupported setting type")
+}
@@ -0,0 +1,83 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ShapeHash returns a short, stable identifier of the SQL text produced by a builder created with
+// NewPredicateBuilder(WithCanonicalShape()), suitable as a prepared statement name or cache key.
+func ShapeHash(where string) string {
+	sum := sha256.Sum256([]byte(where))
+	return hex.EncodeToString(sum[:8]) //nolint:gomnd
+}
+
+// canonicalize returns a copy of the plan node with the operands of "and" and "or" sorted by shape.
+// Operands of the same shape keep their relative order, so the result is deterministic for a given plan.
+func canonicalize(o *filterOp, opts *options) *filterOp {
+	e, ok := o.GetNode().(*filterOpExpression)
+	if !ok {
+		return o
+	}
+	operands := make([]*filterOp, len(e.Expression.Operands))
+	for i, operand := range e.Expression.Operands {
+		operands[i] = canonicalize(operand, opts)
+	}
+	if e.Expression.Operator == "and" || e.Expression.Operator == "or" {
+		keys := make(map[*filterOp]string, len(operands))
+		for _, operand := range operands {
+			keys[operand] = shapeKey(operand, opts)
+		}
+		sort.SliceStable(operands, func(i, j int) bool {
+			return keys[operands[i]] < keys[operands[j]]
+		})
+	}
+	return newExpression(e.Expression.Operator, operands...)
+}
+
+// shapeKey describes a plan node without its literal values. The kind of a value is part of the shape,
+// because it determines how the value is rendered, e.g. a list is bound as an array and null values are not merged.
+func shapeKey(o *filterOp, opts *options) string {
+	b := new(strings.Builder)
+	writeShapeKey(o, opts, b)
+	return b.String()
+}
+
+func writeShapeKey(o *filterOp, opts *options, b *strings.Builder) {
+	switch n := o.GetNode().(type) {
+	case *filterOpExpression:
+		b.WriteString(n.Expression.Operator)
+		b.WriteRune('(')
+		for i, operand := range n.Expression.Operands {
+			if i > 0 {
+				b.WriteRune(',')
+			}
+			writeShapeKey(operand, opts, b)
+		}
+		b.WriteRune(')')
+	case *filterOpVariable:
+		b.WriteString(opts.fieldName(n.Variable))
+	case *filterOpValue:
+		switch n.Value.GetKind().(type) {
+		case *structpb.Value_NullValue:
+			b.WriteString("?null")
+		case *structpb.Value_StringValue:
+			b.WriteString("?string")
+		case *structpb.Value_NumberValue:
+			b.WriteString("?number")
+		case *structpb.Value_BoolValue:
+			b.WriteString("?bool")
+		case *structpb.Value_ListValue:
+			b.WriteString("?list")
+		default:
+			b.WriteString("?struct")
+		}
+	}
+}
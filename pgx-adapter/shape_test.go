@@ -0,0 +1,46 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CanonicalShape(t *testing.T) {
+	is := require.New(t)
+	build := NewPredicateBuilder(WithCanonicalShape())
+
+	// The same policy evaluated for two principals: the values and the order of the operands differ.
+	john := mustExpression(t, `{"expression":{"operator":"or","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+		{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales"]}]}}]}}`)
+	sarah := mustExpression(t, `{"expression":{"operator":"or","operands":[
+		{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","Marketing"]}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"3"}]}}]}}`)
+
+	where1, args1, err := build.BuildPredicate(john)
+	is.NoError(err)
+	where2, args2, err := build.BuildPredicate(sarah)
+	is.NoError(err)
+
+	is.Equal(`("active" = $1) OR ("owner_id" = $2) OR ("department" = ANY($3))`, where1)
+	is.Equal(where1, where2)
+	is.Equal(ShapeHash(where1), ShapeHash(where2))
+	is.Equal([]interface{}{true, "2", []interface{}{"Sales"}}, args1)
+	is.Equal([]interface{}{true, "3", []interface{}{"Sales", "Marketing"}}, args2)
+
+	// A different shape has a different hash.
+	where3, _, err := build.BuildPredicate(mustExpression(t, `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`))
+	is.NoError(err)
+	is.NotEqual(ShapeHash(where1), ShapeHash(where3))
+
+	// The default builder keeps the order of the plan.
+	where, _, err := BuildPredicate(sarah)
+	is.NoError(err)
+	is.Equal(`("department" = ANY($1)) OR ("active" = $2) OR ("owner_id" = $3)`, where)
+}
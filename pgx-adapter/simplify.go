@@ -0,0 +1,212 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// SimplifyFilter returns a filter equivalent to the given one with its condition simplified.
+// If the condition folds to a boolean literal, the filter becomes KIND_ALWAYS_ALLOWED or KIND_ALWAYS_DENIED.
+// The given filter is not modified.
+func SimplifyFilter(filter *enginev1.PlanResourcesFilter) *enginev1.PlanResourcesFilter {
+	if filter == nil || filter.Kind != enginev1.PlanResourcesFilter_KIND_CONDITIONAL || filter.Condition == nil {
+		return filter
+	}
+	c := Simplify(filter.Condition)
+	if b, ok := boolLiteral(c); ok {
+		kind := enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED
+		if b {
+			kind = enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED
+		}
+		return &enginev1.PlanResourcesFilter{Kind: kind}
+	}
+	return &enginev1.PlanResourcesFilter{Kind: filter.Kind, Condition: c}
+}
+
+// Simplify rewrites a plan node into an equivalent, smaller one. It flattens nested and single-operand
+// "and"/"or", removes double negations and duplicate terms, folds operations on literal values and
+// eliminates comparisons with boolean literals, e.g. (a < b) == true becomes a < b.
+// NULL is never folded, so the result evaluates the same way as the input under SQL three-valued logic.
+func Simplify(o *filterOp) *filterOp {
+	e, ok := o.GetNode().(*filterOpExpression)
+	if !ok {
+		return o
+	}
+	operator := e.Expression.Operator
+	operands := make([]*filterOp, len(e.Expression.Operands))
+	for i, operand := range e.Expression.Operands {
+		operands[i] = Simplify(operand)
+	}
+	switch operator {
+	case "and", "or":
+		return simplifyLogical(operator, operands)
+	case "not":
+		if len(operands) != 1 {
+			break
+		}
+		if b, ok := boolLiteral(operands[0]); ok {
+			return boolOperand(!b)
+		}
+		if inner, ok := operands[0].GetNode().(*filterOpExpression); ok && inner.Expression.Operator == "not" && len(inner.Expression.Operands) == 1 {
+			return inner.Expression.Operands[0]
+		}
+	default:
+		if len(operands) != 2 { //nolint:gomnd
+			break
+		}
+		if folded, ok := foldLiterals(operator, operands); ok {
+			return folded
+		}
+		if simplified, ok := eliminateBoolLiteral(operator, operands); ok {
+			return simplified
+		}
+	}
+	return newExpression(operator, operands...)
+}
+
+// simplifyLogical simplifies the already simplified operands of an "and" or an "or".
+func simplifyLogical(operator string, operands []*filterOp) *filterOp {
+	// "and" is absorbed by false and ignores true, "or" is absorbed by true and ignores false.
+	absorbing := operator == "or"
+	var res []*filterOp
+	var add func(o *filterOp)
+	add = func(o *filterOp) {
+		if inner, ok := o.GetNode().(*filterOpExpression); ok && inner.Expression.Operator == operator {
+			for _, io := range inner.Expression.Operands {
+				add(io)
+			}
+			return
+		}
+		for _, r := range res {
+			if proto.Equal(r, o) {
+				return
+			}
+		}
+		res = append(res, o)
+	}
+	for _, o := range operands {
+		if b, ok := boolLiteral(o); ok {
+			if b == absorbing {
+				return boolOperand(absorbing)
+			}
+			continue
+		}
+		add(o)
+	}
+	switch len(res) {
+	case 0:
+		return boolOperand(!absorbing)
+	case 1:
+		return res[0]
+	default:
+		return newExpression(operator, res...)
+	}
+}
+
+// foldLiterals evaluates an operation whose operands are both literal values of the same kind.
+// Values of different kinds are left to the database, which casts one to the type of the other, and so are
+// the orderings of strings, which depend on the collation rather than on the bytes of the strings.
+func foldLiterals(operator string, operands []*filterOp) (*filterOp, bool) {
+	l, ok1 := operands[0].GetNode().(*filterOpValue)
+	r, ok2 := operands[1].GetNode().(*filterOpValue)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	if _, ok := toSQLOp[operator]; !ok {
+		return nil, false
+	}
+	if _, ok := l.Value.GetKind().(*structpb.Value_StringValue); ok && operator != "eq" && operator != "ne" && operator != "in" {
+		return nil, false
+	}
+	if !sameKind(operator, l.Value, r.Value) {
+		return nil, false
+	}
+	v, err := evalBinary(operator, normalize(l.Value.AsInterface()), normalize(r.Value.AsInterface()))
+	if err != nil || v == nil {
+		return nil, false
+	}
+	value, err := structpb.NewValue(v)
+	if err != nil {
+		return nil, false
+	}
+	return &filterOp{Node: &filterOpValue{Value: value}}, true
+}
+
+// sameKind reports whether the operands of a foldable operation are a string, a number or a boolean of the same kind,
+// or, for "in", whether the right-hand operand is a list of values of the kind of the left-hand one.
+func sameKind(operator string, l, r *structpb.Value) bool {
+	switch l.GetKind().(type) {
+	case *structpb.Value_StringValue, *structpb.Value_NumberValue, *structpb.Value_BoolValue:
+	default:
+		return false
+	}
+	if operator != "in" {
+		return fmt.Sprintf("%T", l.GetKind()) == fmt.Sprintf("%T", r.GetKind())
+	}
+	list, ok := r.GetKind().(*structpb.Value_ListValue)
+	if !ok {
+		return false
+	}
+	for _, item := range list.ListValue.GetValues() {
+		if !sameKind("eq", l, item) {
+			return false
+		}
+	}
+	return true
+}
+
+// eliminateBoolLiteral rewrites x == true and x != false as x, and x == false and x != true as not x,
+// where x is an expression. Comparisons of variables are kept because a bare column is not an expression.
+func eliminateBoolLiteral(operator string, operands []*filterOp) (*filterOp, bool) {
+	if operator != "eq" && operator != "ne" {
+		return nil, false
+	}
+	for i, o := range operands {
+		b, ok := boolLiteral(o)
+		if !ok {
+			continue
+		}
+		other := operands[1-i]
+		if _, ok := other.GetNode().(*filterOpExpression); !ok {
+			return nil, false
+		}
+		if b == (operator == "eq") {
+			return other, true
+		}
+		return Simplify(newExpression("not", other)), true
+	}
+	return nil, false
+}
+
+func boolLiteral(o *filterOp) (value, ok bool) {
+	v, ok := o.GetNode().(*filterOpValue)
+	if !ok {
+		return false, false
+	}
+	b, ok := v.Value.GetKind().(*structpb.Value_BoolValue)
+	if !ok {
+		return false, false
+	}
+	return b.BoolValue, true
+}
+
+func boolOperand(b bool) *filterOp {
+	return &filterOp{Node: &filterOpValue{Value: structpb.NewBoolValue(b)}}
+}
+
+func newExpression(operator string, operands ...*filterOp) *filterOp {
+	return &filterOp{
+		Node: &filterOpExpression{
+			Expression: &enginev1.PlanResourcesFilter_Expression{
+				Operator: operator,
+				Operands: operands,
+			},
+		},
+	}
+}
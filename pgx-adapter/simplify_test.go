@@ -0,0 +1,167 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func mustOperand(t *testing.T, input string) *filterOp {
+	t.Helper()
+
+	o := new(filterOp)
+	require.NoError(t, protojson.Unmarshal([]byte(input), o))
+	return o
+}
+
+func Test_Simplify(t *testing.T) {
+	active := `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}}`
+	owner := `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.ownerId"},{"value":"2"}]}}`
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "nested single operand",
+			input: `{"expression":{"operator":"and","operands":[{"expression":{"operator":"or","operands":[` + active + `]}}]}}`,
+			want:  active,
+		},
+		{
+			name:  "flatten",
+			input: `{"expression":{"operator":"or","operands":[` + owner + `,{"expression":{"operator":"or","operands":[` + active + `,` + owner + `]}}]}}`,
+			want:  `{"expression":{"operator":"or","operands":[` + owner + `,` + active + `]}}`,
+		},
+		{
+			name:  "double negation",
+			input: `{"expression":{"operator":"not","operands":[{"expression":{"operator":"not","operands":[` + owner + `]}}]}}`,
+			want:  owner,
+		},
+		{
+			name:  "literal comparison",
+			input: `{"expression":{"operator":"and","operands":[{"expression":{"operator":"eq","operands":[{"value":"Sales"},{"value":"Sales"}]}},` + owner + `]}}`,
+			want:  owner,
+		},
+		{
+			name:  "literal arithmetic",
+			input: `{"expression":{"operator":"lt","operands":[{"variable":"a"},{"expression":{"operator":"add","operands":[{"value":1},{"value":2}]}}]}}`,
+			want:  `{"expression":{"operator":"lt","operands":[{"variable":"a"},{"value":3}]}}`,
+		},
+		{
+			name:  "boolean literal",
+			input: `{"expression":{"operator":"eq","operands":[` + owner + `,{"value":false}]}}`,
+			want:  `{"expression":{"operator":"not","operands":[` + owner + `]}}`,
+		},
+		{
+			name:  "null is not folded",
+			input: `{"expression":{"operator":"eq","operands":[{"value":null},{"value":1}]}}`,
+			want:  `{"expression":{"operator":"eq","operands":[{"value":null},{"value":1}]}}`,
+		},
+		{
+			name:  "different kinds are not folded",
+			input: `{"expression":{"operator":"eq","operands":[{"value":"2"},{"value":2}]}}`,
+			want:  `{"expression":{"operator":"eq","operands":[{"value":"2"},{"value":2}]}}`,
+		},
+		{
+			name:  "string ordering is not folded",
+			input: `{"expression":{"operator":"lt","operands":[{"value":"a"},{"value":"B"}]}}`,
+			want:  `{"expression":{"operator":"lt","operands":[{"value":"a"},{"value":"B"}]}}`,
+		},
+		{
+			name:  "string equality",
+			input: `{"expression":{"operator":"ne","operands":[{"value":"a"},{"value":"B"}]}}`,
+			want:  `{"value":true}`,
+		},
+		{
+			name:  "list of another kind is not folded",
+			input: `{"expression":{"operator":"in","operands":[{"value":"2"},{"value":[1,2]}]}}`,
+			want:  `{"expression":{"operator":"in","operands":[{"value":"2"},{"value":[1,2]}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Simplify(mustOperand(t, tt.input))
+			want := mustOperand(t, tt.want)
+			require.True(t, proto.Equal(want, got), "want %s, got %s", protojson.Format(want), protojson.Format(got))
+		})
+	}
+}
+
+func Test_SimplifyFilter(t *testing.T) {
+	is := require.New(t)
+	sales := `{"expression":{"operator":"eq","operands":[{"value":"Sales"},{"value":"Sales"}]}}`
+	marketing := `{"expression":{"operator":"eq","operands":[{"value":"Sales"},{"value":"Marketing"}]}}`
+	active := `{"expression":{"operator":"eq","operands":[{"variable":"R.attr.active"},{"value":true}]}}`
+
+	filter := SimplifyFilter(&enginev1.PlanResourcesFilter{
+		Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: mustOperand(t, `{"expression":{"operator":"or","operands":[`+active+`,`+sales+`]}}`),
+	})
+	is.Equal(enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED, filter.Kind)
+
+	filter = SimplifyFilter(&enginev1.PlanResourcesFilter{
+		Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: mustOperand(t, `{"expression":{"operator":"and","operands":[`+active+`,`+marketing+`]}}`),
+	})
+	is.Equal(enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED, filter.Kind)
+
+	filter = SimplifyFilter(&enginev1.PlanResourcesFilter{
+		Kind:      enginev1.PlanResourcesFilter_KIND_CONDITIONAL,
+		Condition: mustOperand(t, `{"expression":{"operator":"and","operands":[{"expression":{"operator":"and","operands":[`+active+`]}}]}}`),
+	})
+	is.Equal(enginev1.PlanResourcesFilter_KIND_CONDITIONAL, filter.Kind)
+	where, args, err := BuildPredicate(filter.Condition.GetNode().(*filterOpExpression))
+	is.NoError(err)
+	is.Equal(`"active" = $1`, where)
+	is.Equal([]interface{}{true}, args)
+}
+
+func Test_BuildPredicateSimplified(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   error
+	}{
+		{
+			name:  "true",
+			input: `{"expression":{"operator":"in","operands":[{"value":"Sales"},{"value":["Sales","IT"]}]}}`,
+			want:  "TRUE",
+		},
+		{
+			name:  "false",
+			input: `{"expression":{"operator":"not","operands":[{"expression":{"operator":"eq","operands":[{"value":1},{"value":1}]}}]}}`,
+			want:  "FALSE",
+		},
+		{
+			name:  "variable",
+			input: `{"expression":{"operator":"and","operands":[{"variable":"R.attr.active"}]}}`,
+			err:   ErrExpressionExpected,
+		},
+		{
+			name:  "value",
+			input: `{"expression":{"operator":"or","operands":[{"value":1}]}}`,
+			err:   ErrExpressionExpected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			where, args, err := NewPredicateBuilder(WithSimplify())(mustExpression(t, tt.input))
+			if tt.err != nil {
+				is.ErrorIs(err, tt.err)
+				is.ErrorIs(err, ErrMalformedPlan)
+				return
+			}
+			is.NoError(err)
+			is.Equal(tt.want, where)
+			is.Empty(args)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	sq "github.com/Masterminds/squirrel"
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	responsev1 "github.com/cerbos/cerbos/api/genpb/cerbos/response/v1"
+
+	"github.com/cerbos/cerbos-go-adapters/internal/planfile"
+)
+
+func runSQL(_ context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sql", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pgx-adapter sql [flags] [plan.json|plan.yaml|-]")
+		fmt.Fprintln(fs.Output(), "\nPrints the SQL predicate of a PlanResourcesResponse or a PlanResourcesFilter read from a file or stdin.")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "pgx", "output format: pgx (BuildPredicate) or squirrel (BuildSqlizer)")
+	dialect := fs.String("dialect", "postgres", "SQL dialect: postgres, or sqlite3 with -format squirrel")
+	mapping := fs.String("mapping", "", "YAML file mapping attribute names to column names, e.g. ownerId: owner_id")
+	inline := fs.Bool("inline", false, "inline the arguments in the SQL instead of printing them separately")
+	explainFormat := fs.String("explain", "", "print the plan node of each SQL fragment, as text or json (format pgx only)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("expected at most one plan file")
+	}
+	var opts []Option
+	if *mapping != "" {
+		names, err := planfile.ReadMapping(*mapping)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, WithFieldNames(names))
+	}
+	plan, err := planfile.ReadPlan(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *explainFormat != "" {
+		if *format != "pgx" {
+			return fmt.Errorf("-explain requires format pgx, got %q", *format)
+		}
+		return printExplanation(out, plan, *explainFormat, *inline, opts...)
+	}
+
+	filter := plan.GetFilter()
+	var e *filterOpExpression
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+		_, err = fmt.Fprintln(out, "TRUE")
+		return err
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		_, err = fmt.Fprintln(out, "FALSE")
+		return err
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		var ok bool
+		if e, ok = filter.Condition.GetNode().(*filterOpExpression); !ok {
+			return ErrExpressionExpected
+		}
+	default:
+		return fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+
+	var where string
+	var whereArgs []interface{}
+	switch *format {
+	case "pgx":
+		if *dialect != "postgres" {
+			return fmt.Errorf("format pgx only supports the postgres dialect, got %q", *dialect)
+		}
+		o := newOptions(opts...)
+		o.inlineValues = *inline
+		if where, whereArgs, err = buildPredicate(e, o); err != nil {
+			return err
+		}
+	case "squirrel":
+		// The identifiers are quoted with double quotes, which MySQL reads as strings unless in ANSI_QUOTES mode.
+		if *dialect != "postgres" && *dialect != "sqlite3" {
+			return fmt.Errorf("unsupported dialect %q", *dialect)
+		}
+		s, err := BuildSqlizer(e, opts...)
+		if err != nil {
+			return err
+		}
+		if *inline {
+			_, err = fmt.Fprintln(out, sq.DebugSqlizer(s))
+			return err
+		}
+		if where, whereArgs, err = s.ToSql(); err != nil {
+			return err
+		}
+		if *dialect == "postgres" {
+			if where, err = sq.Dollar.ReplacePlaceholders(where); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+	return printSQL(out, where, whereArgs, *dialect == "postgres")
+}
+
+// printSQL prints the SQL followed by its arguments as SQL comments, labelled $n if the placeholders are numbered,
+// and ?n, the n-th ? placeholder, otherwise.
+func printSQL(out io.Writer, where string, args []interface{}, numbered bool) error {
+	label := "?"
+	if numbered {
+		label = "$"
+	}
+	if _, err := fmt.Fprintln(out, where); err != nil {
+		return err
+	}
+	for i, a := range args {
+		j, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "-- %s%d = %s\n", label, i+1, j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printExplanation prints the explanation of the translation of a plan in the given format.
+func printExplanation(out io.Writer, plan *responsev1.PlanResourcesResponse, format string, inline bool, opts ...Option) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unsupported explain format %q", format)
+	}
+	o := newOptions(opts...)
+	o.inlineValues = inline
+	x, err := explainPlan(plan, o)
+	if err != nil {
+		return err
+	}
+	if format == "text" {
+		_, err = fmt.Fprint(out, x)
+		return err
+	}
+	b, err := x.JSON()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", b)
+	return err
+}
@@ -0,0 +1,99 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunSQL(t *testing.T) {
+	dir := t.TempDir()
+	plan := filepath.Join(dir, "plan.yaml")
+	require.NoError(t, os.WriteFile(plan, []byte(`
+requestId: "1"
+action: read
+resourceKind: contact
+filter:
+  kind: KIND_CONDITIONAL
+  condition:
+    expression:
+      operator: or
+      operands:
+        - expression:
+            operator: eq
+            operands:
+              - variable: request.resource.attr.ownerId
+              - value: "2"
+        - expression:
+            operator: eq
+            operands:
+              - variable: request.resource.attr.active
+              - value: true
+`), 0o600))
+	mapping := filepath.Join(dir, "mapping.yaml")
+	require.NoError(t, os.WriteFile(mapping, []byte("ownerId: owner\n"), 0o600))
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"sql", plan},
+			want: "(\"owner_id\" = $1) OR (\"active\" = $2)\n-- $1 = \"2\"\n-- $2 = true\n",
+		},
+		{
+			args: []string{"sql", "-inline", plan},
+			want: "(\"owner_id\" = '2') OR (\"active\" = TRUE)\n",
+		},
+		{
+			args: []string{"sql", "-format", "squirrel", plan},
+			want: "(\"owner_id\" = $1 OR \"active\" = $2)\n-- $1 = \"2\"\n-- $2 = true\n",
+		},
+		{
+			args: []string{"sql", "-format", "squirrel", "-dialect", "sqlite3", plan},
+			want: "(\"owner_id\" = ? OR \"active\" = ?)\n-- ?1 = \"2\"\n-- ?2 = true\n",
+		},
+		{
+			args: []string{"sql", "-format", "squirrel", "-dialect", "sqlite3", "-inline", plan},
+			want: "(\"owner_id\" = '2' OR \"active\" = 'true')\n",
+		},
+		{
+			args: []string{"sql", "-mapping", mapping, plan},
+			want: "(\"owner\" = $1) OR (\"active\" = $2)\n-- $1 = \"2\"\n-- $2 = true\n",
+		},
+		{
+			args: []string{"sql", "-format", "squirrel", "-mapping", mapping, plan},
+			want: "(\"owner\" = $1 OR \"active\" = $2)\n-- $1 = \"2\"\n-- $2 = true\n",
+		},
+		{
+			args: []string{"sql", "-explain", "text", plan},
+			want: `("owner_id" = $1) OR ("active" = $2)
+-- $1 = "2"
+-- $2 = true
+
+("owner_id" = $1) OR ("active" = $2)
+  <- (or (eq request.resource.attr.ownerId "2") (eq request.resource.attr.active true))
+  ("owner_id" = $1)
+    <- (eq request.resource.attr.ownerId "2")
+  ("active" = $2)
+    <- (eq request.resource.attr.active true)
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			require.NoError(t, run(context.Background(), tt.args, out))
+			require.Equal(t, tt.want, out.String())
+		})
+	}
+
+	require.Error(t, run(context.Background(), []string{"sql", "-format", "squirrel", "-dialect", "mysql", plan}, new(bytes.Buffer)))
+}
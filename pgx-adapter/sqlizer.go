@@ -0,0 +1,177 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// flipOp is used when the value is on the left-hand side of a comparison, e.g. 10 < R.attr.age.
+var flipOp = map[string]string{
+	"eq":  "eq",
+	"ne":  "ne",
+	"lt":  "gt",
+	"lte": "gte",
+	"gt":  "lt",
+	"gte": "lte",
+}
+
+// BuildSqlizer converts a query plan expression into a squirrel.Sqlizer, so that it can be passed to Where
+// of a squirrel builder. The SQL uses "?" placeholders, which the enclosing builder rewrites with its own
+// placeholder format, e.g. sq.Select("*").From("contacts").Where(s).PlaceholderFormat(sq.Dollar).
+// Of the options, WithFieldNames, WithAllowedColumns, WithTableAlias, WithPrincipalAttrs, WithResolver,
+// WithMaxDepth, WithMaxNodes and WithListSpill apply.
+func BuildSqlizer(e *filterOpExpression, opts ...Option) (s sq.Sqlizer, err error) {
+	o := newOptions(opts...)
+	if e != nil {
+		if err = (&limiter{options: o}).checkPlan(e, 1); err != nil {
+			return nil, err
+		}
+	}
+	if e, err = o.resolve(e); err != nil {
+		return nil, err
+	}
+	return buildSqlizer(e, o)
+}
+
+func buildSqlizer(e *filterOpExpression, o *options) (s sq.Sqlizer, err error) {
+	if e == nil {
+		return sq.And{}, nil
+	}
+	operator := e.Expression.Operator
+	switch operator {
+	case "or", "and":
+		ss := make([]sq.Sqlizer, len(e.Expression.Operands))
+		for i, operand := range e.Expression.Operands {
+			if oe, ok := operand.GetNode().(*filterOpExpression); ok {
+				ss[i], err = buildSqlizer(oe, o)
+				if err != nil {
+					return nil, inOperand(err, operator, i)
+				}
+			} else {
+				return nil, inOperand(operandError(CategoryMalformed, "", ErrExpressionExpected), operator, i)
+			}
+		}
+		if operator == "or" {
+			return sq.Or(ss), nil
+		}
+		return sq.And(ss), nil
+	case "not":
+		if len(e.Expression.Operands) != 1 {
+			return nil, expressionError(CategoryMalformed, e, fmt.Errorf("expected a unary operation: op = %q, # of operands = %d", operator, len(e.Expression.Operands)))
+		}
+		if oe, ok := e.Expression.Operands[0].GetNode().(*filterOpExpression); ok {
+			s, err = buildSqlizer(oe, o)
+			if err != nil {
+				return nil, inOperand(err, operator, 0)
+			}
+			return sq.Expr("NOT (?)", s), nil
+		}
+		return nil, inOperand(operandError(CategoryMalformed, "", ErrExpressionExpected), operator, 0)
+	default:
+		if len(e.Expression.Operands) != 2 { //nolint:gomnd
+			return nil, expressionError(CategoryMalformed, e, fmt.Errorf("expected a binary operation: op = %q, # of operands = %d", operator, len(e.Expression.Operands)))
+		}
+		op, ok := toSQLOp[operator]
+		if !ok {
+			return nil, expressionError(CategoryUnsupported, e, fmt.Errorf("unsupported operation %q", operator))
+		}
+		if s, ok, err := buildComparisonSqlizer(operator, e.Expression.Operands, o); ok || err != nil {
+			return s, err
+		}
+		b := new(strings.Builder)
+		var args []interface{}
+		for i, operand := range e.Expression.Operands {
+			switch eo := operand.Node.(type) {
+			case *filterOpExpression:
+				s, err = buildSqlizer(eo, o)
+				if err != nil {
+					return nil, inOperand(err, operator, i)
+				}
+				b.WriteString("(?)")
+				args = append(args, s)
+			case *filterOpVariable:
+				column, err := o.column(eo.Variable)
+				if err != nil {
+					return nil, inOperand(operandError(categoryOf(err), "variable", err), operator, i)
+				}
+				b.WriteString(column)
+			case *filterOpValue:
+				b.WriteRune('?')
+				args = append(args, eo.Value.AsInterface())
+			default:
+				return nil, inOperand(operandError(CategoryMalformed, "", errors.New("unknown Node type")), operator, i)
+			}
+			if i == 0 {
+				b.WriteRune(' ')
+				b.WriteString(op)
+				b.WriteRune(' ')
+			}
+		}
+		return sq.Expr(b.String(), args...), nil
+	}
+}
+
+// buildComparisonSqlizer uses the squirrel comparison types for a comparison between a column and a value.
+// sq.Eq and sq.NotEq turn a nil value into IS [NOT] NULL and a list into [NOT] IN, unless the list is spilled.
+func buildComparisonSqlizer(operator string, operands []*filterOp, o *options) (sq.Sqlizer, bool, error) {
+	var variable string
+	var value interface{}
+	// position and original locate the variable in the plan for the errors, before the operator is flipped.
+	position, original := 0, operator
+	switch l := operands[0].GetNode().(type) {
+	case *filterOpVariable:
+		r, ok := operands[1].GetNode().(*filterOpValue)
+		if !ok {
+			return nil, false, nil
+		}
+		variable, value = l.Variable, r.Value.AsInterface()
+	case *filterOpValue:
+		r, ok := operands[1].GetNode().(*filterOpVariable)
+		if !ok {
+			return nil, false, nil
+		}
+		if operator, ok = flipOp[operator]; !ok {
+			return nil, false, nil
+		}
+		variable, value, position = r.Variable, l.Value.AsInterface(), 1
+	default:
+		return nil, false, nil
+	}
+	column, err := o.column(variable)
+	if err != nil {
+		return nil, false, inOperand(operandError(categoryOf(err), "variable", err), original, position)
+	}
+	_, isList := value.([]interface{})
+	switch {
+	case operator == "in" && isList && o.spill(len(value.([]interface{}))):
+		return sq.Expr(column+" = ANY(?)", value), true, nil
+	case operator == "in" && isList:
+		return sq.Eq{column: value}, true, nil
+	case isList:
+		return nil, false, nil
+	case value == nil && operator != "eq" && operator != "ne":
+		// squirrel refuses to compare NULL with an ordering operator.
+		return nil, false, nil
+	}
+	switch operator {
+	case "eq":
+		return sq.Eq{column: value}, true, nil
+	case "ne":
+		return sq.NotEq{column: value}, true, nil
+	case "lt":
+		return sq.Lt{column: value}, true, nil
+	case "lte":
+		return sq.LtOrEq{column: value}, true, nil
+	case "gt":
+		return sq.Gt{column: value}, true, nil
+	case "gte":
+		return sq.GtOrEq{column: value}, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BuildSqlizer(t *testing.T) {
+	tests := []struct {
+		input string
+		sql   string
+		args  []interface{}
+	}{
+		{
+			input: `{"expression":{"operator":"and","operands":[
+				{"expression":{"operator":"eq","operands":[{"variable":"R.attr.status"},{"value":"PENDING_APPROVAL"}]}},
+				{"expression":{"operator":"ne","operands":[{"variable":"R.attr.owner"},{"value":"maggie"}]}}]}}`,
+			sql:  `("status" = ? AND "owner" <> ?)`,
+			args: []interface{}{"PENDING_APPROVAL", "maggie"},
+		},
+		{
+			input: `{"expression":{"operator":"lt","operands":[{"expression":{"operator":"add","operands":[{"variable":"a"},{"variable":"b"}]}},{"value":10}]}}`,
+			sql:   `("a" + "b") < ?`,
+			args:  []interface{}{float64(10)},
+		},
+		{
+			input: `{"expression":{"operator":"gt","operands":[{"value":10},{"variable":"R.attr.age"}]}}`,
+			sql:   `"age" < ?`,
+			args:  []interface{}{float64(10)},
+		},
+		{
+			input: `{"expression":{"operator":"or","operands":[
+				{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","Marketing"]}]}},
+				{"expression":{"operator":"not","operands":[{"expression":{"operator":"eq","operands":[{"variable":"R.attr.companyId"},{"value":null}]}}]}}]}}`,
+			sql:  `("department" IN (?,?) OR NOT ("company_id" IS NULL))`,
+			args: []interface{}{"Sales", "Marketing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			is := require.New(t)
+			s, err := BuildSqlizer(mustExpression(t, tt.input))
+			is.NoError(err)
+			sql, args, err := s.ToSql()
+			is.NoError(err)
+			is.Equal(tt.sql, sql)
+			is.Equal(tt.args, args)
+		})
+	}
+}
+
+func Test_BuildSqlizerPlaceholderFormat(t *testing.T) {
+	is := require.New(t)
+	s, err := BuildSqlizer(mustExpression(t, `{"expression":{"operator":"or","operands":[
+		{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.ownerId"},{"value":"2"}]}},
+		{"expression":{"operator":"eq","operands":[{"variable":"request.resource.attr.active"},{"value":true}]}}]}}`))
+	is.NoError(err)
+	sql, args, err := sq.Select("*").
+		From("contacts").
+		Where(sq.Eq{"company_id": 1}).
+		Where(s).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	is.NoError(err)
+	is.Equal(`SELECT * FROM contacts WHERE company_id = $1 AND ("owner_id" = $2 OR "active" = $3)`, sql)
+	is.Equal([]interface{}{1, "2", true}, args)
+}
+
+func Test_BuildSqlizerListSpill(t *testing.T) {
+	is := require.New(t)
+	e := mustExpression(t, `{"expression":{"operator":"in","operands":[{"variable":"R.attr.department"},{"value":["Sales","IT","Marketing"]}]}}`)
+
+	s, err := BuildSqlizer(e, WithListSpill(2))
+	is.NoError(err)
+	sql, args, err := sq.Select("*").From("contacts").Where(s).PlaceholderFormat(sq.Dollar).ToSql()
+	is.NoError(err)
+	is.Equal(`SELECT * FROM contacts WHERE "department" = ANY($1)`, sql)
+	is.Equal([]interface{}{[]interface{}{"Sales", "IT", "Marketing"}}, args)
+
+	s, err = BuildSqlizer(e, WithListSpill(3))
+	is.NoError(err)
+	sql, args, err = s.ToSql()
+	is.NoError(err)
+	is.Equal(`"department" IN (?,?,?)`, sql)
+	is.Len(args, 3)
+}
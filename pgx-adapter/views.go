@@ -0,0 +1,126 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	enginev1 "github.com/cerbos/cerbos/api/genpb/cerbos/engine/v1"
+	"github.com/jackc/pgx/v5"
+)
+
+// View is a database view restricting a table to the rows allowed by a query plan.
+type View struct {
+	Name  string
+	Query string
+}
+
+// BuildView returns the view named name over table, e.g. "contacts_for_sales" over "cerbforce.contacts".
+// Both names can be schema-qualified. Literal values are inlined in the query, because a view cannot have parameters.
+// A view is shared by every principal allowed to read it, so a plan referring to the principal fails with a
+// PrincipalAttributeError, unless its attributes are given with WithPrincipalAttrs.
+func BuildView(name, table string, filter *enginev1.PlanResourcesFilter, opts ...Option) (*View, error) {
+	if filter == nil {
+		return nil, errors.New("\"filter\" is nil")
+	}
+	query := "SELECT * FROM " + quoteIdentifier(table)
+	switch filter.Kind {
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_ALLOWED:
+	case enginev1.PlanResourcesFilter_KIND_ALWAYS_DENIED:
+		query += " WHERE false"
+	case enginev1.PlanResourcesFilter_KIND_CONDITIONAL:
+		e, ok := filter.Condition.GetNode().(*filterOpExpression)
+		if !ok {
+			return nil, ErrExpressionExpected
+		}
+		o := newOptions(opts...)
+		o.inlineValues = true
+		if o.principalAttrs == nil {
+			o.principalAttrs = map[string]any{}
+		}
+		where, _, err := buildPredicate(e, o)
+		if err != nil {
+			return nil, err
+		}
+		if where != "" {
+			query += " WHERE " + where
+		}
+	default:
+		return nil, fmt.Errorf("unexpected filter.Kind: %s", filter.Kind)
+	}
+	return &View{Name: name, Query: query}, nil
+}
+
+// Statement returns the CREATE OR REPLACE VIEW statement of the view.
+func (v *View) Statement() string {
+	return "CREATE OR REPLACE VIEW " + quoteIdentifier(v.Name) + " AS " + v.Query + ";"
+}
+
+// ViewStatus is the result of comparing a View with the database.
+type ViewStatus int
+
+const (
+	ViewUnchanged ViewStatus = iota
+	ViewNew
+	ViewChanged
+)
+
+func (s ViewStatus) String() string {
+	switch s {
+	case ViewUnchanged:
+		return "unchanged"
+	case ViewNew:
+		return "new"
+	case ViewChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("ViewStatus(%d)", int(s))
+}
+
+// DiffView compares a View with the view of the same name in the database.
+// Postgres stores view definitions in a normalised form, so the query is compared with the existing definition by
+// creating it as a temporary view in a transaction that is rolled back and comparing the output of pg_get_viewdef.
+func DiffView(ctx context.Context, db txBeginner, v *View) (ViewStatus, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var existing *string
+	if err := tx.QueryRow(ctx, "SELECT pg_get_viewdef(to_regclass($1))", quoteIdentifier(v.Name)).Scan(&existing); err != nil {
+		return 0, fmt.Errorf("failed to read view %q: %w", v.Name, err)
+	}
+	if existing == nil {
+		return ViewNew, nil
+	}
+	const tmp = "cerbos_view_diff"
+	if _, err := tx.Exec(ctx, "CREATE TEMPORARY VIEW "+tmp+" AS "+v.Query); err != nil {
+		return 0, fmt.Errorf("failed to create view %q: %w", v.Name, err)
+	}
+	var candidate string
+	if err := tx.QueryRow(ctx, "SELECT pg_get_viewdef($1::regclass)", tmp).Scan(&candidate); err != nil {
+		return 0, fmt.Errorf("failed to read view %q: %w", v.Name, err)
+	}
+	if candidate != *existing {
+		return ViewChanged, nil
+	}
+	return ViewUnchanged, nil
+}
+
+// applyViews runs the statements of the views in a transaction.
+func applyViews(ctx context.Context, db txBeginner, views []*View) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		for _, v := range views {
+			if _, err := tx.Exec(ctx, v.Statement()); err != nil {
+				return fmt.Errorf("failed to create view %q: %w", v.Name, err)
+			}
+		}
+		return nil
+	})
+}
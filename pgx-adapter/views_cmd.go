@@ -0,0 +1,162 @@
+// Copyright 2021-2025 Zenauth Ltd.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cerbos/cerbos-sdk-go/cerbos"
+	"github.com/ghodss/yaml"
+	"github.com/jackc/pgx/v5"
+)
+
+// ViewSpec lists the views generated by the views command, e.g.
+//
+//	resource: contact
+//	action: read
+//	table: cerbforce.contacts
+//	views:
+//	  - name: contacts_for_sales
+//	    roles: [user]
+//	    attr:
+//	      department: Sales
+//
+// A view is shared by the principals with the given roles and attributes, so the plan is computed for a principal
+// with the ID viewPrincipalID, and a plan depending on the principal ID, e.g. through an ownership condition, is rejected.
+type ViewSpec struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Table    string `json:"table"`
+	Views    []struct {
+		Name  string         `json:"name"`
+		Roles []string       `json:"roles"`
+		Attr  map[string]any `json:"attr"`
+	} `json:"views"`
+}
+
+// viewPrincipalID is the ID of the principal the plans of the views are computed for. It is not a valid ID of
+// the principals of an application, so it only appears in a plan that compares the principal ID with a column.
+const viewPrincipalID = "cerbos:view-principal"
+
+func runViews(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("views", flag.ContinueOnError)
+	specFile := fs.String("spec", "", "YAML file listing the views to generate (required)")
+	cerbosAddr := fs.String("cerbos", "localhost:3593", "gRPC address of the Cerbos PDP")
+	plaintext := fs.Bool("plaintext", false, "connect to the Cerbos PDP without TLS")
+	dbURL := fs.String("db", "", "Postgres connection URL; if set, the views are compared with the existing ones and only new or changed views are printed")
+	apply := fs.Bool("apply", false, "create the new and changed views in the database given with -db")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *specFile == "" {
+		fs.Usage()
+		return errors.New("-spec is required")
+	}
+	if *apply && *dbURL == "" {
+		return errors.New("-apply requires -db")
+	}
+
+	specBytes, err := os.ReadFile(*specFile)
+	if err != nil {
+		return err
+	}
+	var spec ViewSpec
+	if err := yaml.Unmarshal(specBytes, &spec); err != nil {
+		return fmt.Errorf("failed to read %s: %w", *specFile, err)
+	}
+	if spec.Resource == "" || spec.Table == "" {
+		return fmt.Errorf("%s: resource and table are required", *specFile)
+	}
+	if spec.Action == "" {
+		spec.Action = "read"
+	}
+	for _, vs := range spec.Views {
+		if vs.Name == "" {
+			return fmt.Errorf("%s: name is required for every view", *specFile)
+		}
+	}
+
+	var opts []cerbos.Opt
+	if *plaintext {
+		opts = append(opts, cerbos.WithPlaintext())
+	}
+	c, err := cerbos.New(*cerbosAddr, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create Cerbos client: %w", err)
+	}
+
+	views := make([]*View, len(spec.Views))
+	for i, vs := range spec.Views {
+		principal := cerbos.NewPrincipal(viewPrincipalID).WithRoles(vs.Roles...)
+		for k, v := range vs.Attr {
+			principal = principal.WithAttr(k, v)
+		}
+		plan, err := c.PlanResources(ctx, principal, cerbos.NewResource(spec.Resource, ""), spec.Action)
+		if err != nil {
+			return fmt.Errorf("view %q: failed to compute the query plan: %w", vs.Name, err)
+		}
+		if refersTo(plan.GetFilter().GetCondition(), viewPrincipalID) {
+			return fmt.Errorf("view %q: the plan depends on the principal ID, which a view shared by the role cannot express", vs.Name)
+		}
+		if views[i], err = BuildView(vs.Name, spec.Table, plan.GetFilter()); err != nil {
+			return fmt.Errorf("view %q: %w", vs.Name, err)
+		}
+	}
+
+	if *dbURL == "" {
+		for _, v := range views {
+			fmt.Fprintln(out, v.Statement())
+		}
+		return nil
+	}
+
+	conn, err := pgx.Connect(ctx, *dbURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	var changed []*View
+	for _, v := range views {
+		status, err := DiffView(ctx, conn, v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "-- %s: %s\n", v.Name, status)
+		if status != ViewUnchanged {
+			fmt.Fprintln(out, v.Statement())
+			changed = append(changed, v)
+		}
+	}
+	if *apply && len(changed) > 0 {
+		return applyViews(ctx, conn, changed)
+	}
+	return nil
+}
+
+// refersTo reports whether the plan operand has the string value s, on its own or in a list.
+func refersTo(operand *filterOp, s string) bool {
+	switch n := operand.GetNode().(type) {
+	case *filterOpExpression:
+		for _, o := range n.Expression.Operands {
+			if refersTo(o, s) {
+				return true
+			}
+		}
+	case *filterOpValue:
+		if n.Value.GetStringValue() == s {
+			return true
+		}
+		for _, item := range n.Value.GetListValue().GetValues() {
+			if item.GetStringValue() == s {
+				return true
+			}
+		}
+	}
+	return false
+}